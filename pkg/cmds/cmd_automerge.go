@@ -0,0 +1,284 @@
+package cmds
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mdevilliers/org-scrounger/pkg/cmds/logging"
+	"github.com/mdevilliers/org-scrounger/pkg/cmds/output"
+	"github.com/mdevilliers/org-scrounger/pkg/gh"
+	"github.com/mdevilliers/org-scrounger/pkg/util"
+	"github.com/shurcooL/githubv4"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	defaultMaxMerges = 10
+
+	automergeEligible = "eligible"
+	automergeSkipped  = "skipped"
+	automergeApproved = "approved"
+	automergeMerged   = "merged"
+	automergeFailed   = "failed"
+)
+
+// mergeMethods are the methods github can merge a pull request with
+var mergeMethods = []string{
+	string(githubv4.PullRequestMergeMethodMerge),
+	string(githubv4.PullRequestMergeMethodSquash),
+	string(githubv4.PullRequestMergeMethodRebase),
+}
+
+// automergeEntry records what was done, or would have been done, to a pull request
+type automergeEntry struct {
+	Time   time.Time `json:"time"`
+	Repo   string    `json:"repo"`
+	Number int       `json:"number"`
+	Title  string    `json:"title"`
+	URL    string    `json:"url"`
+	Author string    `json:"author"`
+	Action string    `json:"action"`
+	Reason string    `json:"reason,omitempty"`
+	DryRun bool      `json:"dry_run"`
+}
+
+func automergeCmd() *cli.Command { //nolint: funlen, gocyclo
+	return &cli.Command{
+		Name:  "automerge",
+		Usage: "approve and merge pull requests raised by bots e.g. dependabot or renovate",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "topic",
+				Value: "",
+				Usage: "specify repository topic to predicate on",
+			},
+			&cli.StringFlag{
+				Name:     "owner",
+				Value:    "",
+				Usage:    "github organisation",
+				Required: true,
+			},
+			output.CLIOutputJSONFlag,
+			&cli.BoolFlag{
+				Name:  "log-rate-limit",
+				Value: false,
+				Usage: "log the rate limit metrics from github",
+			},
+			&cli.StringSliceFlag{
+				Name:  "bot",
+				Value: []string{"dependabot[bot]", "renovate[bot]"},
+				Usage: "logins of the bots whose pull requests are merged, github apps have the suffix [bot] e.g. dependabot[bot]",
+			},
+			&cli.BoolFlag{
+				Name:  "approve",
+				Value: false,
+				Usage: "approve eligible pull requests",
+			},
+			&cli.BoolFlag{
+				Name:  "merge",
+				Value: false,
+				Usage: "merge eligible pull requests",
+			},
+			&cli.StringFlag{
+				Name:  "merge-method",
+				Value: "SQUASH",
+				Usage: "merge method [MERGE, SQUASH, REBASE]",
+			},
+			&cli.BoolFlag{
+				Name:  "dry-run",
+				Value: true,
+				Usage: "report what would be done without approving or merging. Use --dry-run=false to make changes",
+			},
+			&cli.IntFlag{
+				Name:  "max",
+				Value: defaultMaxMerges,
+				Usage: "maximum number of pull requests to approve or merge in a run",
+			},
+			&cli.StringFlag{
+				Name:  "audit-log",
+				Value: "",
+				Usage: "append a JSON line for each pull request considered to this file",
+			},
+		}, githubFlags()...),
+		Action: func(ctx context.Context, c *cli.Command) error {
+
+			mergeMethod := strings.ToUpper(c.String("merge-method"))
+			if !util.Contains(mergeMethods, mergeMethod) {
+				return fmt.Errorf("error : unknown merge method %s, expected one of %s", c.String("merge-method"), strings.Join(mergeMethods, ", "))
+			}
+
+			opts := []gh.Option{}
+			if !c.Bool("dry-run") {
+				// never act on cached mergeable or CI states
+				opts = append(opts, gh.WithoutCache())
+			}
+
+			ghClient, err := githubClientFromCLI(ctx, c, opts...)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				logging.LogRunCost(ghClient.RateLimit())
+			}()
+
+			topic := c.String("topic")
+			owner := c.String("owner")
+			approve := c.Bool("approve")
+			merge := c.Bool("merge")
+			dryRun := c.Bool("dry-run")
+			maxActions := int(c.Int("max"))
+			auditLog := c.String("audit-log")
+			logRateLimit := c.Bool("log-rate-limit")
+
+			log := logging.GetRateLimitLogger(logRateLimit)
+
+			// github apps have the '[bot]' suffix, other logins are machine users
+			filter := gh.PullRequestFilter{Authors: c.StringSlice("bot")}
+
+			if topic != "" {
+				repos, rateLimit, err := ghClient.GetReposWithTopic(ctx, owner, topic)
+				log(rateLimit)
+				if err != nil {
+					return err
+				}
+				for _, r := range repos {
+					filter.Repos = append(filter.Repos, r.Name)
+				}
+				if len(filter.Repos) == 0 {
+					return fmt.Errorf("error : no repositories found with topic %s", topic)
+				}
+			}
+
+			prs, rateLimit, err := ghClient.SearchPullRequests(ctx, owner, true, filter)
+			log(rateLimit)
+			if err != nil {
+				return err
+			}
+
+			// oldest first
+			sort.SliceStable(prs, func(i, j int) bool {
+				return prs[i].CreatedAt.Before(prs[j].CreatedAt.Time)
+			})
+
+			audit, err := openAuditLog(auditLog)
+			if err != nil {
+				return err
+			}
+			defer audit.Close()
+
+			all := []automergeEntry{}
+			actioned := 0
+			now := time.Now()
+
+			for _, pr := range prs {
+				if !filter.Matches(pr, now) {
+					continue
+				}
+				entry := automergeEntry{
+					Time:   time.Now(),
+					Repo:   string(pr.Repository.Name),
+					Number: int(pr.Number),
+					Title:  string(pr.Title),
+					URL:    string(pr.URL),
+					Author: string(pr.Author.Login),
+					Action: automergeEligible,
+					DryRun: dryRun,
+				}
+
+				switch {
+				case bool(pr.IsDraft):
+					entry.Action, entry.Reason = automergeSkipped, "draft"
+				case !pr.IsMergable():
+					entry.Action, entry.Reason = automergeSkipped, fmt.Sprintf("mergeable state is %s", pr.Mergeable)
+				case !pr.LastCommitBuilds():
+					entry.Action, entry.Reason = automergeSkipped, fmt.Sprintf("last commit state is '%s'", pr.LastCommitState())
+				case !approve && !merge:
+					// eligible but nothing to do
+				case actioned >= maxActions:
+					entry.Action, entry.Reason = automergeSkipped, fmt.Sprintf("maximum of %d pull requests per run reached", maxActions)
+				default:
+					actioned++
+					if dryRun {
+						entry.Reason = "dry run"
+						break
+					}
+					entry.Action, entry.Reason = automergePullRequest(ctx, ghClient, pr, approve, merge, mergeMethod)
+				}
+				all = append(all, entry)
+
+				// recorded straight away so an interrupted run doesn't lose what was done
+				if err := audit.Write(entry); err != nil {
+					return err
+				}
+			}
+
+			outputter, err := output.GetFromCLIContext(c)
+			if err != nil {
+				return err
+			}
+			return outputter(all)
+		},
+	}
+}
+
+// automergePullRequest approves and/or merges the pull request returning the action taken
+func automergePullRequest(ctx context.Context, ghClient *gh.Client, pr gh.PullRequest, approve, merge bool, method string) (string, string) { //nolint: lll
+	action := automergeEligible
+	if approve {
+		if err := ghClient.ApprovePullRequest(ctx, pr, "Approved by scrng automerge"); err != nil {
+			return automergeFailed, err.Error()
+		}
+		action = automergeApproved
+	}
+	if merge {
+		if err := ghClient.MergePullRequest(ctx, pr, method); err != nil {
+			return automergeFailed, err.Error()
+		}
+		action = automergeMerged
+	}
+	return action, ""
+}
+
+// auditLog appends entries as JSON lines to a file
+type auditLog struct {
+	f *os.File
+}
+
+// openAuditLog opens the file at path for appending, nothing is
+// written if the path is empty
+func openAuditLog(path string) (*auditLog, error) {
+	if path == "" {
+		return &auditLog{}, nil
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) //nolint: gomnd
+	if err != nil {
+		return nil, fmt.Errorf("error opening audit log %s: %w", path, err)
+	}
+	return &auditLog{f: f}, nil
+}
+
+// Write appends the entry and flushes it to disk
+func (a *auditLog) Write(entry automergeEntry) error {
+	if a.f == nil {
+		return nil
+	}
+	if err := json.NewEncoder(a.f).Encode(entry); err != nil {
+		return fmt.Errorf("error writing audit log %s: %w", a.f.Name(), err)
+	}
+	if err := a.f.Sync(); err != nil {
+		return fmt.Errorf("error writing audit log %s: %w", a.f.Name(), err)
+	}
+	return nil
+}
+
+func (a *auditLog) Close() error {
+	if a.f == nil {
+		return nil
+	}
+	return a.f.Close()
+}
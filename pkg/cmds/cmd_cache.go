@@ -0,0 +1,71 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mdevilliers/org-scrounger/pkg/cmds/output"
+	"github.com/urfave/cli/v3"
+)
+
+func cacheCmd() *cli.Command {
+	return &cli.Command{
+		Name:  "cache",
+		Usage: "inspect and purge cached github responses",
+		Commands: []*cli.Command{
+			cacheListCommand(),
+			cachePurgeCommand(),
+		},
+	}
+}
+
+func cacheListCommand() *cli.Command {
+	return &cli.Command{
+		Name: "list",
+		Flags: []cli.Flag{
+			cacheDirFlag(),
+			output.CLIOutputJSONFlag,
+		},
+		Action: func(_ context.Context, c *cli.Command) error {
+			cache, err := cacheFromCLI(c, 0)
+			if err != nil {
+				return err
+			}
+			entries, err := cache.Entries()
+			if err != nil {
+				return err
+			}
+			outputter, err := output.GetFromCLIContext(c)
+			if err != nil {
+				return err
+			}
+			return outputter(entries)
+		},
+	}
+}
+
+func cachePurgeCommand() *cli.Command {
+	return &cli.Command{
+		Name: "purge",
+		Flags: []cli.Flag{
+			cacheDirFlag(),
+			&cli.BoolFlag{
+				Name:  "expired",
+				Value: false,
+				Usage: "only purge expired entries",
+			},
+		},
+		Action: func(_ context.Context, c *cli.Command) error {
+			cache, err := cacheFromCLI(c, 0)
+			if err != nil {
+				return err
+			}
+			n, err := cache.Purge(c.Bool("expired"))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("purged %d entries from %s\n", n, cache.Dir())
+			return nil
+		},
+	}
+}
@@ -2,16 +2,49 @@ package cmds
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/alitto/pond"
+	"github.com/mdevilliers/org-scrounger/pkg/cmds/logging"
 	"github.com/mdevilliers/org-scrounger/pkg/cmds/output"
+	"github.com/mdevilliers/org-scrounger/pkg/drift"
 	"github.com/mdevilliers/org-scrounger/pkg/gh"
 	"github.com/mdevilliers/org-scrounger/pkg/mapping"
 	"github.com/mdevilliers/org-scrounger/pkg/providers/images"
+	"github.com/mdevilliers/org-scrounger/pkg/registry"
 	"github.com/mdevilliers/org-scrounger/pkg/sonarcloud"
+	"github.com/rs/zerolog/log"
 	"github.com/urfave/cli/v3"
 )
 
+// kustomizeBinaryFlag falls back to the kustomize binary on the PATH
+// rather than building kustomizations in-process
+var kustomizeBinaryFlag = &cli.BoolFlag{
+	Name:  "kustomize-binary",
+	Usage: "run the kustomize binary on the PATH rather than building in-process",
+}
+
+// freshnessFlag compares the tag of each image with the newest semver tag in its registry
+var freshnessFlag = &cli.BoolFlag{
+	Name:  "freshness",
+	Usage: "add the latest_version, versions_behind and is_latest of each image from its container registry",
+}
+
+// provenanceFlag reads the commit each image was built from and counts the commits not yet deployed
+var provenanceFlag = &cli.BoolFlag{
+	Name:  "provenance",
+	Usage: "add the revision and source of each image from its OCI labels and the commits on the default branch of its repo since the revision",
+}
+
+// imagePathFlag adds JSONPaths to images in CRDs that aren't in a pod spec
+var imagePathFlag = &cli.StringSliceFlag{
+	Name:  "image-path",
+	Usage: "JSONPath, evaluated against each manifest, to an image in a CRD e.g. '$.spec.image'",
+}
+
 type imageProvider interface {
 	Images(ctx context.Context) ([]mapping.Image, error)
 }
@@ -21,8 +54,13 @@ func imagesCmd() *cli.Command {
 		Name: "images",
 		Commands: []*cli.Command{
 			imagesArgoCommand(),
+			imagesClusterCommand(),
+			imagesDiffCommand(),
+			imagesHelmCommand(),
 			imagesJaegarCommand(),
 			imagesKustomizeCommand(),
+			imagesManifestsCommand(),
+			imagesRegistryCommand(),
 		},
 	}
 }
@@ -30,7 +68,7 @@ func imagesCmd() *cli.Command {
 func imagesArgoCommand() *cli.Command {
 	return &cli.Command{
 		Name: "argo",
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.StringSliceFlag{
 				Name:    "path",
 				Aliases: []string{"p"},
@@ -40,25 +78,81 @@ func imagesArgoCommand() *cli.Command {
 				Name:  "mapping",
 				Usage: "path to a mapping file",
 			},
-			&cli.BoolFlag{
-				Name:  "delete-cache-on-exit",
-				Usage: "deletes all caches on exit",
-			},
+			kustomizeBinaryFlag,
+			imagePathFlag,
+			freshnessFlag,
+			provenanceFlag,
 			output.CLIOutputJSONFlag,
-		},
+		}, append(append(argoFlags(), registryFlags()...), githubFlags()...)...),
 		Action: func(ctx context.Context, c *cli.Command) error {
 			paths := c.StringSlice("path")
-			deleteCache := c.Bool("delete-cache-on-exit")
-			argo := images.NewArgo(deleteCache, paths...)
+			options, err := argoOptionsFromCLI(c)
+			if err != nil {
+				return err
+			}
+			argo := images.NewArgo(options, paths...)
 			return getImages(ctx, c, argo)
 		},
 	}
 }
 
+// argoFlags configure how argo applications are checked out
+func argoFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "delete-cache-on-exit",
+			Usage: "checkout to a temporary directory deleted on exit rather than the checkout directory",
+		},
+		&cli.StringFlag{
+			Name:    "checkout-dir",
+			Usage:   "directory to cache git checkouts and charts in. Defaults to the user cache directory",
+			Sources: cli.EnvVars("SCRNG_CHECKOUT_DIR"),
+		},
+		&cli.IntFlag{
+			Name:  "checkout-concurrency",
+			Usage: "maximum number of git checkouts made in parallel",
+			Value: 4, //nolint: gomnd
+		},
+		&cli.BoolFlag{
+			Name:  "sparse-checkout",
+			Usage: "only checkout the path of each application. Kustomizations referencing files outside of the path will fail",
+		},
+		&cli.DurationFlag{
+			Name:  "checkout-lock-timeout",
+			Usage: "how long to wait for another run using the checkout directory",
+			Value: 10 * time.Minute, //nolint: gomnd
+		},
+	}
+}
+
+func argoOptionsFromCLI(c *cli.Command) (images.ArgoOptions, error) {
+	endpoints, err := githubEndpointsFromCLI(c)
+	if err != nil {
+		return images.ArgoOptions{}, err
+	}
+	checkoutDir := c.String("checkout-dir")
+	if checkoutDir == "" {
+		checkoutDir, err = images.DefaultCheckoutDir()
+		if err != nil {
+			return images.ArgoOptions{}, err
+		}
+	}
+	return images.ArgoOptions{
+		DeleteCacheOnExit:  c.Bool("delete-cache-on-exit"),
+		UseKustomizeBinary: c.Bool(kustomizeBinaryFlag.Name),
+		GithubURL:          endpoints.Web,
+		CheckoutDir:        checkoutDir,
+		Concurrency:        int(c.Int("checkout-concurrency")),
+		SparseCheckout:     c.Bool("sparse-checkout"),
+		LockTimeout:        c.Duration("checkout-lock-timeout"),
+		ImagePaths:         c.StringSlice(imagePathFlag.Name),
+	}, nil
+}
+
 func imagesKustomizeCommand() *cli.Command {
 	return &cli.Command{
 		Name: "kustomize",
-		Flags: []cli.Flag{
+		Flags: append(append([]cli.Flag{
 			&cli.StringSliceFlag{
 				Name:    "root",
 				Aliases: []string{"r"},
@@ -68,20 +162,359 @@ func imagesKustomizeCommand() *cli.Command {
 				Name:  "mapping",
 				Usage: "path to a mapping file",
 			},
+			kustomizeBinaryFlag,
+			imagePathFlag,
+			freshnessFlag,
+			provenanceFlag,
 			output.CLIOutputJSONFlag,
-		},
+		}, registryFlags()...), githubFlags()...),
 		Action: func(ctx context.Context, c *cli.Command) error {
 			roots := c.StringSlice("root")
-			kustomize := images.NewKustomize(roots...)
+			kustomize := images.NewKustomize(c.Bool(kustomizeBinaryFlag.Name), c.StringSlice(imagePathFlag.Name), roots...)
 			return getImages(ctx, c, kustomize)
 		},
 	}
 }
 
+func imagesClusterCommand() *cli.Command {
+	return &cli.Command{
+		Name: "cluster",
+		Flags: append(append([]cli.Flag{
+			&cli.StringFlag{
+				Name:    "kubeconfig",
+				Usage:   "path to a kubeconfig file, defaults to the standard loading rules",
+				Sources: cli.EnvVars("KUBECONFIG"),
+			},
+			&cli.StringFlag{
+				Name:  "context",
+				Usage: "kubeconfig context, defaults to the current context",
+			},
+			&cli.StringSliceFlag{
+				Name:    "namespace",
+				Aliases: []string{"n"},
+				Usage:   "namespace to list, defaults to all namespaces",
+			},
+			&cli.StringFlag{
+				Name:  "namespace-selector",
+				Usage: "label selector used to find namespaces e.g. team=payments",
+			},
+			&cli.BoolFlag{
+				Name:  "pods",
+				Usage: "include pods that aren't managed by a controller",
+			},
+			&cli.StringFlag{
+				Name:  "mapping",
+				Usage: "path to a mapping file",
+			},
+			freshnessFlag,
+			provenanceFlag,
+			output.CLIOutputJSONFlag,
+		}, registryFlags()...), githubFlags()...),
+		Action: func(ctx context.Context, c *cli.Command) error {
+			client, err := images.NewKubernetesClient(c.String("kubeconfig"), c.String("context"))
+			if err != nil {
+				return err
+			}
+			cluster := images.NewCluster(client, images.ClusterOptions{
+				Namespaces:        c.StringSlice("namespace"),
+				NamespaceSelector: c.String("namespace-selector"),
+				IncludePods:       c.Bool("pods"),
+			})
+			return getImages(ctx, c, cluster)
+		},
+	}
+}
+
+func imagesManifestsCommand() *cli.Command {
+	return &cli.Command{
+		Name: "manifests",
+		Flags: append(append([]cli.Flag{
+			&cli.StringSliceFlag{
+				Name:     "path",
+				Aliases:  []string{"p"},
+				Usage:    "path to a directory or file of kubernetes manifests",
+				Required: true,
+			},
+			&cli.StringSliceFlag{
+				Name:  "include",
+				Usage: "only read files matching the glob, defaults to *.yaml, *.yml and *.json",
+			},
+			&cli.StringSliceFlag{
+				Name:  "exclude",
+				Usage: "skip files matching the glob",
+			},
+			&cli.StringFlag{
+				Name:  "mapping",
+				Usage: "path to a mapping file",
+			},
+			imagePathFlag,
+			freshnessFlag,
+			provenanceFlag,
+			output.CLIOutputJSONFlag,
+		}, registryFlags()...), githubFlags()...),
+		Action: func(ctx context.Context, c *cli.Command) error {
+			manifests, err := images.NewManifests(c.StringSlice("include"), c.StringSlice("exclude"), c.StringSlice(imagePathFlag.Name), c.StringSlice("path")...)
+			if err != nil {
+				return err
+			}
+			return getImages(ctx, c, manifests)
+		},
+	}
+}
+
+func imagesRegistryCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "registry",
+		Usage: "list the tags of the repositories in container registries",
+		Flags: append(append([]cli.Flag{
+			&cli.StringSliceFlag{
+				Name:    "repository",
+				Aliases: []string{"r"},
+				Usage:   "container repository to list e.g. registry:5000 or registry:5000/team. Defaults to the container_repositories of the mapping file",
+			},
+			&cli.StringFlag{
+				Name:  "mapping",
+				Usage: "path to a mapping file",
+			},
+			freshnessFlag,
+			provenanceFlag,
+			output.CLIOutputJSONFlag,
+		}, registryFlags()...), githubFlags()...),
+		Action: func(ctx context.Context, c *cli.Command) error {
+			repositories := c.StringSlice("repository")
+			if len(repositories) == 0 && c.String("mapping") != "" {
+				mapper, err := mapping.LoadFromFile(c.String("mapping"))
+				if err != nil {
+					return fmt.Errorf("error creating mapper: %w", err)
+				}
+				repositories = mapper.ContainerRepositories()
+			}
+			if len(repositories) == 0 {
+				return errors.New("no container repositories specified via --repository or the mapping file")
+			}
+			client, err := registryClientFromCLI(c)
+			if err != nil {
+				return err
+			}
+			provider := images.NewRegistry(client, repositories...)
+			return getImages(ctx, c, provider)
+		},
+	}
+}
+
+func imagesHelmCommand() *cli.Command {
+	return &cli.Command{
+		Name: "helm",
+		Flags: append(append([]cli.Flag{
+			&cli.StringSliceFlag{
+				Name:     "chart",
+				Aliases:  []string{"c"},
+				Usage:    "path to a chart directory or packaged chart",
+				Required: true,
+			},
+			&cli.StringSliceFlag{
+				Name:    "values",
+				Aliases: []string{"f"},
+				Usage:   "path to a values file, later files take precedence",
+			},
+			&cli.StringSliceFlag{
+				Name:  "set",
+				Usage: "set values e.g. image.tag=1.2.3",
+			},
+			&cli.StringSliceFlag{
+				Name:  "set-string",
+				Usage: "set STRING values e.g. image.tag=1.2",
+			},
+			&cli.StringFlag{
+				Name:  "release-name",
+				Usage: "release name used to render the chart",
+				Value: "release",
+			},
+			&cli.StringFlag{
+				Name:  "namespace",
+				Usage: "namespace used to render the chart",
+				Value: "default",
+			},
+			&cli.StringFlag{
+				Name:  "mapping",
+				Usage: "path to a mapping file",
+			},
+			imagePathFlag,
+			freshnessFlag,
+			provenanceFlag,
+			output.CLIOutputJSONFlag,
+		}, registryFlags()...), githubFlags()...),
+		Action: func(ctx context.Context, c *cli.Command) error {
+			helm := images.NewHelm(images.HelmOptions{
+				ReleaseName:  c.String("release-name"),
+				Namespace:    c.String("namespace"),
+				ValueFiles:   c.StringSlice("values"),
+				Values:       c.StringSlice("set"),
+				StringValues: c.StringSlice("set-string"),
+				ImagePaths:   c.StringSlice(imagePathFlag.Name),
+			}, c.StringSlice("chart")...)
+			return getImages(ctx, c, helm)
+		},
+	}
+}
+
+func imagesDiffCommand() *cli.Command { //nolint: funlen
+	return &cli.Command{
+		Name:  "diff",
+		Usage: "compare the versions of images deployed to environments",
+		Flags: append([]cli.Flag{
+			&cli.StringSliceFlag{
+				Name:     "env",
+				Aliases:  []string{"e"},
+				Usage:    "an environment as name=provider:path[,path] e.g. prod=kustomize:overlays/prod. Providers are [argo, cluster, helm, kustomize, manifests, registry], the path of the cluster provider is the kubeconfig context and of the registry provider a container repository",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "mapping",
+				Usage: "path to a mapping file, images are aligned by their mapped repo",
+			},
+			&cli.BoolFlag{
+				Name:  "drift-only",
+				Usage: "only output services with different versions or missing from an environment",
+			},
+			kustomizeBinaryFlag,
+			imagePathFlag,
+			output.CLIOutputJSONFlag,
+		}, append(append(argoFlags(), registryFlags()...), githubFlags()...)...),
+		Action: func(ctx context.Context, c *cli.Command) error {
+
+			ghClient, err := githubClientFromCLI(ctx, c)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				logging.LogRunCost(ghClient.RateLimit())
+			}()
+
+			var mapper *mapping.Mapper
+			if mappingFile := c.String("mapping"); mappingFile != "" {
+				mapper, err = mapping.LoadFromFile(mappingFile)
+				if err != nil {
+					return fmt.Errorf("error creating mapper: %w", err)
+				}
+			}
+
+			environments := []drift.Environment{}
+			for _, spec := range c.StringSlice("env") {
+				name, provider, err := environmentProvider(c, spec)
+				if err != nil {
+					return err
+				}
+				all, err := provider.Images(ctx)
+				if err != nil {
+					return fmt.Errorf("error listing images for %s: %w", name, err)
+				}
+				for n := range all {
+					if err := decorateImage(ctx, mapper, ghClient, &all[n]); err != nil {
+						return err
+					}
+				}
+				environments = append(environments, drift.Environment{Name: name, Images: all})
+			}
+
+			services := drift.Compare(environments)
+
+			pool := pond.New(5, 0, pond.MinWorkers(3)) //nolint: gomnd
+			defer pool.StopAndWait()
+			group, gctx := pool.GroupContext(ctx)
+
+			for n := range services {
+				s := &services[n]
+				owner, name, found := s.Owner()
+				if !found {
+					continue
+				}
+				group.Submit(func() error {
+					tags, _, err := ghClient.GetTags(gctx, owner, name)
+					if err != nil {
+						return fmt.Errorf("error getting tags for %s/%s: %w", owner, name, err)
+					}
+					s.Behind(tags)
+					return nil
+				})
+			}
+			if err := group.Wait(); err != nil {
+				return err
+			}
+
+			outputter, err := output.GetFromCLIContext(c)
+			if err != nil {
+				return err
+			}
+			for _, s := range services {
+				if c.Bool("drift-only") && !s.Drifted && len(s.Missing) == 0 {
+					continue
+				}
+				if err := outputter(s); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// environmentProvider returns the name and provider of an environment
+// specified as 'name=provider:path[,path]' e.g. 'prod=kustomize:overlays/prod'
+func environmentProvider(c *cli.Command, spec string) (string, imageProvider, error) {
+
+	name, rest, found := strings.Cut(spec, "=")
+	if !found || name == "" {
+		return "", nil, fmt.Errorf("error parsing environment '%s': expected name=provider:path", spec)
+	}
+	kind, path, found := strings.Cut(rest, ":")
+	if !found || path == "" {
+		return "", nil, fmt.Errorf("error parsing environment '%s': expected name=provider:path", spec)
+	}
+	paths := strings.Split(path, ",")
+	imagePaths := c.StringSlice(imagePathFlag.Name)
+
+	switch kind {
+	case "argo":
+		options, err := argoOptionsFromCLI(c)
+		if err != nil {
+			return "", nil, err
+		}
+		return name, images.NewArgo(options, paths...), nil
+	case "cluster":
+		client, err := images.NewKubernetesClient("", path)
+		if err != nil {
+			return "", nil, err
+		}
+		return name, images.NewCluster(client, images.ClusterOptions{}), nil
+	case "helm":
+		return name, images.NewHelm(images.HelmOptions{
+			ReleaseName: "release",
+			Namespace:   "default",
+			ImagePaths:  imagePaths,
+		}, paths...), nil
+	case "kustomize":
+		return name, images.NewKustomize(c.Bool(kustomizeBinaryFlag.Name), imagePaths, paths...), nil
+	case "manifests":
+		manifests, err := images.NewManifests(nil, nil, imagePaths, paths...)
+		if err != nil {
+			return "", nil, err
+		}
+		return name, manifests, nil
+	case "registry":
+		client, err := registryClientFromCLI(c)
+		if err != nil {
+			return "", nil, err
+		}
+		return name, images.NewRegistry(client, paths...), nil
+	}
+	return "", nil, fmt.Errorf("error parsing environment '%s': unknown provider '%s'", spec, kind)
+}
+
 func imagesJaegarCommand() *cli.Command {
 	return &cli.Command{
 		Name: "jaegar",
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:  "mapping",
 				Usage: "path to a mapping file",
@@ -97,7 +530,7 @@ func imagesJaegarCommand() *cli.Command {
 				Required: true,
 			},
 			output.CLIOutputJSONFlag,
-		},
+		}, githubFlags()...),
 		Action: func(ctx context.Context, c *cli.Command) error {
 
 			jaegarURL := c.String("jaegar-url")
@@ -112,7 +545,13 @@ func imagesJaegarCommand() *cli.Command {
 func getImages(ctx context.Context, c *cli.Command, provider imageProvider) error {
 
 	mappingFile := c.String("mapping")
-	ghClient := gh.NewClientFromEnv(ctx)
+	ghClient, err := githubClientFromCLI(ctx, c)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		logging.LogRunCost(ghClient.RateLimit())
+	}()
 
 	all, err := provider.Images(ctx)
 
@@ -138,27 +577,93 @@ func getImages(ctx context.Context, c *cli.Command, provider imageProvider) erro
 		return err
 	}
 
+	var (
+		freshness  *registry.Freshness
+		provenance *registry.Provenance
+	)
+	if c.Bool(freshnessFlag.Name) || c.Bool(provenanceFlag.Name) {
+		client, err := registryClientFromCLI(c)
+		if err != nil {
+			return err
+		}
+		if c.Bool(freshnessFlag.Name) {
+			freshness = registry.NewFreshness(client)
+		}
+		if c.Bool(provenanceFlag.Name) {
+			provenance = registry.NewProvenance(client)
+		}
+	}
+
 	for n := range all {
 
 		image := all[n]
-		if mapper != nil {
-			clientFound, sonarcloudClient, err := sonarcloud.NewClientFromEnv("https://sonarcloud.io")
-			if clientFound && err != nil {
-				return fmt.Errorf("error creating sonarcloud client: %w", err)
+		if freshness != nil {
+			// registries may not be reachable or need other credentials so carry on
+			if err := freshness.Decorate(ctx, &image); err != nil {
+				log.Warn().Err(err).Msgf("error checking freshness of image '%s'", image.Name)
 			}
-			if clientFound {
-				if _, err := mapper.Decorate(ctx, ghClient, sonarcloudClient, &image); err != nil {
-					return fmt.Errorf("error mapping image '%s' to repo and sonarcloud: %w", image.Name, err)
-				}
-			} else {
-				if _, err := mapper.Decorate(ctx, ghClient, nil, &image); err != nil {
-					return fmt.Errorf("error mapping image '%s' to repo: %w", image.Name, err)
-				}
+		}
+		if provenance != nil {
+			if err := provenance.Decorate(ctx, &image); err != nil {
+				log.Warn().Err(err).Msgf("error reading provenance of image '%s'", image.Name)
 			}
 		}
+		if err := decorateImage(ctx, mapper, ghClient, &image); err != nil {
+			return err
+		}
+		if provenance != nil {
+			decorateUndeployedCommits(ctx, ghClient, &image)
+		}
 		if err := outputter(image); err != nil {
 			return err
 		}
 	}
 	return nil
 }
+
+// decorateUndeployedCommits counts the commits on the default branch of the repo, either the
+// mapped repo or the source of the image, since the revision the image was built from
+func decorateUndeployedCommits(ctx context.Context, ghClient *gh.Client, image *mapping.Image) {
+	if image.Revision == "" {
+		return
+	}
+	repoURL := ""
+	switch {
+	case image.Repo != nil && image.Repo.URL != "":
+		repoURL = image.Repo.URL
+	case strings.HasPrefix(image.Source, ghClient.Endpoints().Web+"/"):
+		repoURL = image.Source
+	}
+	owner, name, found := gh.ParseRepoURL(repoURL)
+	if !found {
+		return
+	}
+	count, _, err := ghClient.GetUndeployedCommits(ctx, owner, name, image.Revision)
+	if err != nil {
+		// the revision may not have been pushed or the repo may be elsewhere
+		log.Warn().Err(err).Msgf("error counting undeployed commits of image '%s'", image.Name)
+		return
+	}
+	image.UndeployedCommits = &count
+}
+
+// decorateImage maps the image to its repo, and sonarcloud if configured, if there is a mapper
+func decorateImage(ctx context.Context, mapper *mapping.Mapper, ghClient *gh.Client, image *mapping.Image) error {
+	if mapper == nil {
+		return nil
+	}
+	clientFound, sonarcloudClient, err := sonarcloud.NewClientFromEnv("https://sonarcloud.io")
+	if clientFound && err != nil {
+		return fmt.Errorf("error creating sonarcloud client: %w", err)
+	}
+	if clientFound {
+		if _, err := mapper.Decorate(ctx, ghClient, sonarcloudClient, image); err != nil {
+			return fmt.Errorf("error mapping image '%s' to repo and sonarcloud: %w", image.Name, err)
+		}
+	} else {
+		if _, err := mapper.Decorate(ctx, ghClient, nil, image); err != nil {
+			return fmt.Errorf("error mapping image '%s' to repo: %w", image.Name, err)
+		}
+	}
+	return nil
+}
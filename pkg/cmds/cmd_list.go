@@ -12,7 +12,7 @@ import (
 func listCmd() *cli.Command {
 	return &cli.Command{
 		Name: "list",
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:  "topic",
 				Value: "",
@@ -35,10 +35,16 @@ func listCmd() *cli.Command {
 				Value: false,
 				Usage: "log the rate limit metrics from github",
 			},
-		},
+		}, githubFlags()...),
 		Action: func(ctx context.Context, c *cli.Command) error {
 
-			ghClient := gh.NewClientFromEnv(ctx)
+			ghClient, err := githubClientFromCLI(ctx, c)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				logging.LogRunCost(ghClient.RateLimit())
+			}()
 
 			topic := c.String("topic")
 			owner := c.String("owner")
@@ -8,14 +8,13 @@ import (
 
 	"github.com/mdevilliers/org-scrounger/pkg/cmds/logging"
 	"github.com/mdevilliers/org-scrounger/pkg/exec"
-	"github.com/mdevilliers/org-scrounger/pkg/gh"
 	"github.com/urfave/cli/v3"
 )
 
 func mgCmd() *cli.Command { //nolint:funlen
 	return &cli.Command{
 		Name: "mg",
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:  "topic",
 				Value: "",
@@ -70,10 +69,16 @@ func mgCmd() *cli.Command { //nolint:funlen
 				Value: "",
 				Usage: "languge selector e.g Go",
 			},
-		},
+		}, githubFlags()...),
 		Action: func(ctx context.Context, c *cli.Command) error {
 
-			ghClient := gh.NewClientFromEnv(ctx)
+			ghClient, err := githubClientFromCLI(ctx, c)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				logging.LogRunCost(ghClient.RateLimit())
+			}()
 
 			topic := c.String("topic")
 			owner := c.String("owner")
@@ -0,0 +1,160 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mdevilliers/org-scrounger/pkg/cmds/logging"
+	"github.com/mdevilliers/org-scrounger/pkg/cmds/output"
+	"github.com/mdevilliers/org-scrounger/pkg/gh"
+	"github.com/urfave/cli/v3"
+)
+
+func prsCmd() *cli.Command { //nolint: funlen
+	return &cli.Command{
+		Name:  "prs",
+		Usage: "list open pull requests across an organisation",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "topic",
+				Value: "",
+				Usage: "specify repository topic to predicate on",
+			},
+			&cli.StringFlag{
+				Name:     "owner",
+				Value:    "",
+				Usage:    "github organisation",
+				Required: true,
+			},
+			output.CLIOutputTemplateJSONFlag,
+			output.CLITemplateFileFlag,
+			&cli.BoolFlag{
+				Name:  "omit-archived",
+				Value: false,
+				Usage: "omit archived repositories",
+			},
+			&cli.BoolFlag{
+				Name:  "log-rate-limit",
+				Value: false,
+				Usage: "log the rate limit metrics from github",
+			},
+			&cli.StringSliceFlag{
+				Name:  "author",
+				Usage: "only include pull requests by these authors, the logins of bots have the suffix [bot] e.g. dependabot[bot]",
+			},
+			&cli.StringSliceFlag{
+				Name:  "label",
+				Usage: "only include pull requests with any of these labels",
+			},
+			&cli.DurationFlag{
+				Name:  "older-than",
+				Usage: "only include pull requests older than this e.g. 72h",
+			},
+			&cli.DurationFlag{
+				Name:  "newer-than",
+				Usage: "only include pull requests newer than this e.g. 24h",
+			},
+			&cli.StringSliceFlag{
+				Name:  "review-state",
+				Usage: "only include pull requests in these review states [APPROVED, CHANGES_REQUESTED, REVIEW_REQUIRED]",
+			},
+			&cli.StringFlag{
+				Name:  "draft",
+				Value: "",
+				Usage: "filter on the draft state of pull requests [true, false]",
+			},
+			&cli.StringSliceFlag{
+				Name:  "ci-state",
+				Usage: "only include pull requests whose last commit is in these states [SUCCESS, FAILURE, PENDING, ERROR]",
+			},
+		}, githubFlags()...),
+		Action: func(ctx context.Context, c *cli.Command) error {
+
+			ghClient, err := githubClientFromCLI(ctx, c)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				logging.LogRunCost(ghClient.RateLimit())
+			}()
+
+			topic := c.String("topic")
+			owner := c.String("owner")
+			omitArchived := c.Bool("omit-archived")
+			logRateLimit := c.Bool("log-rate-limit")
+
+			log := logging.GetRateLimitLogger(logRateLimit)
+
+			filter, err := pullRequestFilterFromCLI(c)
+			if err != nil {
+				return err
+			}
+
+			if topic != "" {
+				repos, rateLimit, err := ghClient.GetReposWithTopic(ctx, owner, topic)
+				log(rateLimit)
+				if err != nil {
+					return err
+				}
+				for _, r := range repos {
+					filter.Repos = append(filter.Repos, r.Name)
+				}
+				if len(filter.Repos) == 0 {
+					return fmt.Errorf("error : no repositories found with topic %s", topic)
+				}
+			}
+
+			prs, rateLimit, err := ghClient.SearchPullRequests(ctx, owner, omitArchived, filter)
+			log(rateLimit)
+			if err != nil {
+				return err
+			}
+
+			type Data struct {
+				PullRequests []gh.PullRequest `json:"pull_requests"`
+			}
+
+			all := Data{PullRequests: []gh.PullRequest{}}
+			now := time.Now()
+			for _, pr := range prs {
+				if filter.Matches(pr, now) {
+					all.PullRequests = append(all.PullRequests, pr)
+				}
+			}
+			sort.SliceStable(all.PullRequests, func(i, j int) bool {
+				return all.PullRequests[i].CreatedAt.Before(all.PullRequests[j].CreatedAt.Time)
+			})
+
+			outputter, err := output.GetFromCLIContext(c)
+			if err != nil {
+				return err
+			}
+			return outputter(all)
+		},
+	}
+}
+
+func pullRequestFilterFromCLI(c *cli.Command) (gh.PullRequestFilter, error) {
+	filter := gh.PullRequestFilter{
+		Authors:      c.StringSlice("author"),
+		Labels:       c.StringSlice("label"),
+		OlderThan:    c.Duration("older-than"),
+		NewerThan:    c.Duration("newer-than"),
+		ReviewStates: c.StringSlice("review-state"),
+		CIStates:     c.StringSlice("ci-state"),
+	}
+	switch c.String("draft") {
+	case "":
+	case "true":
+		draft := true
+		filter.Draft = &draft
+	case "false":
+		draft := false
+		filter.Draft = &draft
+	default:
+		return filter, fmt.Errorf("error : unknown draft state %s - needs to be true or false", c.String("draft"))
+	}
+	return filter, nil
+}
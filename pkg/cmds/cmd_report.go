@@ -3,7 +3,6 @@ package cmds
 import (
 	"context"
 	"errors"
-	"fmt"
 	"sync"
 
 	"github.com/alitto/pond"
@@ -17,7 +16,7 @@ import (
 func reportCmd() *cli.Command { //nolint: funlen
 	return &cli.Command{
 		Name: "report",
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			&cli.StringFlag{
 				Name:  "topic",
 				Value: "",
@@ -51,21 +50,33 @@ func reportCmd() *cli.Command { //nolint: funlen
 				Aliases: []string{"nr"},
 				Usage:   "specify repos that aren't released e.g. a development library or a POC",
 			},
+			&cli.StringFlag{
+				Name:  "branch",
+				Value: "",
+				Usage: "branch to inspect, defaults to each repository's default branch",
+			},
 			&cli.StringSliceFlag{
 				Name:    "skip",
 				Aliases: []string{"s"},
 				Usage:   "specify repos to skip",
 			},
-		},
+		}, githubFlags()...),
 		Action: func(ctx context.Context, c *cli.Command) error {
 
-			ghClient := gh.NewClientFromEnv(ctx)
+			ghClient, err := githubClientFromCLI(ctx, c)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				logging.LogRunCost(ghClient.RateLimit())
+			}()
 
 			topic := c.String("topic")
 			repo := c.String("repo")
 			owner := c.String("owner")
 			notReleased := c.StringSlice("not-released")
 			skipList := c.StringSlice("skip")
+			branch := c.String("branch")
 			omitArchived := c.Bool("omit-archived")
 			logRateLimit := c.Bool("log-rate-limit")
 
@@ -78,13 +89,12 @@ func reportCmd() *cli.Command { //nolint: funlen
 			}
 
 			repos := []gh.RepositorySlim{}
-			var err error
 			var rateLimit gh.RateLimit
 
 			if repo != "" {
 				repos = append(repos, gh.RepositorySlim{
 					Name: repo,
-					URL:  fmt.Sprintf("https://github.com/%s/%s", owner, repo),
+					URL:  ghClient.Endpoints().RepoURL(owner, repo),
 				})
 			} else {
 				repos, rateLimit, err = ghClient.GetReposWithTopic(ctx, owner, topic)
@@ -96,6 +106,8 @@ func reportCmd() *cli.Command { //nolint: funlen
 
 			type (
 				Details struct {
+					// Branch is the name of the branch inspected
+					Branch            string               `json:"branch"`
 					Details           gh.Repository        `json:"details"`
 					UnreleasedCommits gh.UnreleasedCommits `json:"unreleased_commits"`
 				}
@@ -125,7 +137,7 @@ func reportCmd() *cli.Command { //nolint: funlen
 
 				group.Submit(func() error {
 
-					repoDetails, rateLimit, err := ghClient.GetRepoDetails(ctx, owner, reponame)
+					repoDetails, rateLimit, err := ghClient.GetRepoDetails(ctx, owner, reponame, branch)
 					log(rateLimit)
 					if err != nil {
 						return err
@@ -134,11 +146,12 @@ func reportCmd() *cli.Command { //nolint: funlen
 					defer allmutex.Unlock()
 
 					all.Repositories[reponame] = Details{
+						Branch:  repoDetails.Branch(),
 						Details: repoDetails,
 					}
 
 					if util.Contains(notReleased, reponame) {
-						unreleasedCommits, rateLimit, err := ghClient.GetUnreleasedCommitsForRepo(ctx, owner, reponame)
+						unreleasedCommits, rateLimit, err := ghClient.GetUnreleasedCommitsForRepo(ctx, owner, reponame, branch)
 						log(rateLimit)
 						if err != nil {
 							return err
@@ -0,0 +1,161 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/alitto/pond"
+	"github.com/mdevilliers/org-scrounger/pkg/cmds/logging"
+	"github.com/mdevilliers/org-scrounger/pkg/cmds/output"
+	"github.com/mdevilliers/org-scrounger/pkg/util"
+	"github.com/mdevilliers/org-scrounger/pkg/vulns"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	csvOutputStr   = "csv"
+	sarifOutputStr = "sarif"
+)
+
+func vulnsCmd() *cli.Command { //nolint: funlen
+	return &cli.Command{
+		Name:  "vulns",
+		Usage: "aggregate vulnerability alerts across repositories",
+		Flags: append([]cli.Flag{
+			&cli.StringFlag{
+				Name:  "topic",
+				Value: "",
+				Usage: "specify repository topic to predicate on",
+			},
+			&cli.StringFlag{
+				Name:     "owner",
+				Value:    "",
+				Usage:    "github organisation",
+				Required: true,
+			},
+			&cli.StringFlag{
+				Name:  "output",
+				Value: output.JSONOutputStr,
+				Usage: fmt.Sprintf("specify output format [template, %s, %s, %s]. Default is '%s'.",
+					output.JSONOutputStr, csvOutputStr, sarifOutputStr, output.JSONOutputStr),
+			},
+			output.CLITemplateFileFlag,
+			&cli.BoolFlag{
+				Name:  "omit-archived",
+				Value: false,
+				Usage: "omit archived repositories",
+			},
+			&cli.BoolFlag{
+				Name:  "log-rate-limit",
+				Value: false,
+				Usage: "log the rate limit metrics from github",
+			},
+			&cli.StringSliceFlag{
+				Name:  "severity",
+				Usage: "only include alerts with these severities [CRITICAL, HIGH, MODERATE, LOW]",
+			},
+			&cli.StringSliceFlag{
+				Name:    "skip",
+				Aliases: []string{"s"},
+				Usage:   "specify repos to skip",
+			},
+		}, githubFlags()...),
+		Action: func(ctx context.Context, c *cli.Command) error {
+
+			ghClient, err := githubClientFromCLI(ctx, c)
+			if err != nil {
+				return err
+			}
+			defer func() {
+				logging.LogRunCost(ghClient.RateLimit())
+			}()
+
+			topic := c.String("topic")
+			owner := c.String("owner")
+			omitArchived := c.Bool("omit-archived")
+			severities := c.StringSlice("severity")
+			skipList := c.StringSlice("skip")
+			logRateLimit := c.Bool("log-rate-limit")
+
+			log := logging.GetRateLimitLogger(logRateLimit)
+
+			repos, rateLimit, err := ghClient.GetReposWithTopic(ctx, owner, topic)
+			log(rateLimit)
+			if err != nil {
+				return err
+			}
+
+			all := []vulns.RepoAlerts{}
+			allmutex := sync.Mutex{}
+
+			pool := pond.New(5, 0, pond.MinWorkers(3)) //nolint: gomnd
+			defer pool.StopAndWait()
+			group, ctx := pool.GroupContext(ctx)
+
+			for _, repo := range repos {
+
+				if omitArchived && repo.IsArchived {
+					continue
+				}
+				if util.Contains(skipList, repo.Name) {
+					continue
+				}
+				repo := repo
+
+				group.Submit(func() error {
+					alerts, rateLimit, err := ghClient.GetVulnerabilityAlerts(ctx, owner, repo.Name)
+					log(rateLimit)
+					if err != nil {
+						return err
+					}
+					if len(severities) > 0 {
+						alerts = output.PredicateOnSeverity(alerts, upper(severities)...)
+					}
+					allmutex.Lock()
+					defer allmutex.Unlock()
+					all = append(all, vulns.RepoAlerts{
+						Name:   repo.Name,
+						URL:    repo.URL,
+						Alerts: alerts,
+					})
+					return nil
+				})
+			}
+			if err := group.Wait(); err != nil {
+				return err
+			}
+
+			groups := vulns.Aggregate(all)
+
+			if !c.IsSet("template-file") {
+				switch c.String("output") {
+				case csvOutputStr:
+					return vulns.WriteCSV(os.Stdout, groups)
+				case sarifOutputStr:
+					return vulns.WriteSARIF(os.Stdout, groups)
+				}
+			}
+
+			outputter, err := output.GetFromCLIContext(c)
+			if err != nil {
+				return err
+			}
+
+			type Data struct {
+				Vulnerabilities []vulns.Group `json:"vulnerabilities"`
+			}
+			return outputter(Data{Vulnerabilities: groups})
+		},
+	}
+}
+
+func upper(in []string) []string {
+	ret := []string{}
+	for _, s := range in {
+		ret = append(ret, strings.ToUpper(s))
+	}
+	return ret
+}
@@ -15,5 +15,9 @@ func Commands() []*cli.Command {
 		listCmd(),
 		imagesCmd(),
 		mgCmd(),
+		prsCmd(),
+		automergeCmd(),
+		vulnsCmd(),
+		cacheCmd(),
 	}
 }
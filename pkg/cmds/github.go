@@ -0,0 +1,145 @@
+package cmds
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mdevilliers/org-scrounger/pkg/gh"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	defaultCacheTTL           = 10 * time.Minute
+	defaultRateLimitThreshold = 100
+	defaultMaxRetries         = 3
+	defaultRetryBackoff       = time.Second
+)
+
+// githubFlags returns the flags used to configure the github client
+func githubFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "no-cache",
+			Value: false,
+			Usage: "do not read or write cached github responses",
+		},
+		&cli.BoolFlag{
+			Name:  "refresh",
+			Value: false,
+			Usage: "ignore cached github responses, updating the cache with fresh ones",
+		},
+		&cli.DurationFlag{
+			Name:  "cache-ttl",
+			Value: defaultCacheTTL,
+			Usage: "how long cached github responses are valid for",
+		},
+		cacheDirFlag(),
+		&cli.StringFlag{
+			Name:    "github-url",
+			Value:   gh.DefaultAPIURL,
+			Usage:   "root of the github REST API e.g. https://github.example.com/api/v3 for a Github Enterprise Server",
+			Sources: cli.EnvVars("GITHUB_API_URL"),
+		},
+		&cli.IntFlag{
+			Name:  "rate-limit-threshold",
+			Value: defaultRateLimitThreshold,
+			Usage: "pause querying github until the rate limit resets when the remaining quota drops below this value. 0 disables pausing",
+		},
+		&cli.IntFlag{
+			Name:    "github-app-id",
+			Usage:   "authenticate as a github app with this ID rather than using GITHUB_TOKEN",
+			Sources: cli.EnvVars("GITHUB_APP_ID"),
+		},
+		&cli.IntFlag{
+			Name:    "github-app-installation-id",
+			Usage:   "installation ID of the github app",
+			Sources: cli.EnvVars("GITHUB_APP_INSTALLATION_ID"),
+		},
+		&cli.StringFlag{
+			Name:    "github-app-private-key",
+			Usage:   "path to the PEM encoded private key of the github app",
+			Sources: cli.EnvVars("GITHUB_APP_PRIVATE_KEY_PATH"),
+		},
+		&cli.IntFlag{
+			Name:  "max-pages",
+			Value: 0,
+			Usage: "maximum number of pages fetched for each of the pull requests, alerts, checks, topics and languages of a repository. 0 fetches every page",
+		},
+		&cli.IntFlag{
+			Name:  "max-retries",
+			Value: defaultMaxRetries,
+			Usage: "maximum number of retries for github secondary rate limit and server errors",
+		},
+	}
+}
+
+func cacheDirFlag() cli.Flag {
+	return &cli.StringFlag{
+		Name:    "cache-dir",
+		Value:   "",
+		Usage:   "directory to cache github responses in. Defaults to the user cache directory",
+		Sources: cli.EnvVars("SCRNG_CACHE_DIR"),
+	}
+}
+
+// githubClientFromCLI returns a github client configured via the flags from githubFlags
+func githubClientFromCLI(ctx context.Context, c *cli.Command, extra ...gh.Option) (*gh.Client, error) {
+	endpoints, err := githubEndpointsFromCLI(c)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []gh.Option{
+		gh.WithEndpoints(endpoints),
+		gh.WithRateLimitThreshold(int(c.Int("rate-limit-threshold"))),
+		gh.WithRetries(int(c.Int("max-retries")), defaultRetryBackoff),
+		gh.WithMaxPages(int(c.Int("max-pages"))),
+	}
+
+	if !c.Bool("no-cache") {
+		cache, err := cacheFromCLI(c, c.Duration("cache-ttl"))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, gh.WithCache(cache, c.Bool("refresh")))
+	}
+	opts = append(opts, extra...)
+
+	appID := c.Int("github-app-id")
+	if appID == 0 {
+		return gh.NewClientFromEnv(ctx, opts...), nil
+	}
+
+	installationID := c.Int("github-app-installation-id")
+	keyPath := c.String("github-app-private-key")
+	if installationID == 0 || keyPath == "" {
+		return nil, fmt.Errorf("error : supply an installation ID and private key for github app %d", appID)
+	}
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading github app private key: %w", err)
+	}
+	return gh.NewClientFromGithubApp(ctx, appID, installationID, key, opts...)
+}
+
+func githubEndpointsFromCLI(c *cli.Command) (gh.Endpoints, error) {
+	return gh.ParseAPIURL(c.String("github-url"))
+}
+
+func cacheFromCLI(c *cli.Command, ttl time.Duration) (*gh.Cache, error) {
+	dir := c.String("cache-dir")
+	if dir == "" {
+		d, err := gh.DefaultCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = d
+	}
+	cache, err := gh.NewCache(dir, ttl)
+	if err != nil {
+		return nil, fmt.Errorf("error creating github cache: %w", err)
+	}
+	return cache, nil
+}
@@ -22,3 +22,13 @@ func GetRateLimitLogger(logRateLimit bool) func(gh.RateLimit) {
 		log.Info().Interface("rate-limit", r).Send()
 	}
 }
+
+// LogRunCost logs the rate limit at the end of a run including
+// the total cost of all of the queries made. Nothing is logged if
+// github wasn't queried e.g. every response was cached.
+func LogRunCost(r gh.RateLimit) {
+	if r.Limit == 0 {
+		return
+	}
+	log.Info().Interface("rate-limit", r).Msg("github run cost")
+}
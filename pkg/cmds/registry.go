@@ -0,0 +1,48 @@
+package cmds
+
+import (
+	"errors"
+
+	"github.com/mdevilliers/org-scrounger/pkg/registry"
+	"github.com/urfave/cli/v3"
+)
+
+// registryFlags returns the flags used to configure the container registry client
+func registryFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringFlag{
+			Name:    "registry-host",
+			Usage:   "registry the credentials are for e.g. ghcr.io, other registries are accessed anonymously",
+			Sources: cli.EnvVars("SCRNG_REGISTRY_HOST"),
+		},
+		&cli.StringFlag{
+			Name:    "registry-username",
+			Usage:   "username used to authenticate with the registry",
+			Sources: cli.EnvVars("SCRNG_REGISTRY_USERNAME"),
+		},
+		&cli.StringFlag{
+			Name:    "registry-password",
+			Usage:   "password or token used to authenticate with the registry",
+			Sources: cli.EnvVars("SCRNG_REGISTRY_PASSWORD"),
+		},
+		&cli.StringSliceFlag{
+			Name:  "plain-http",
+			Usage: "access the registry over http rather than https e.g. registry:5000. Loopback registries always use http",
+		},
+	}
+}
+
+// registryClientFromCLI returns a registry client configured via the flags from registryFlags
+func registryClientFromCLI(c *cli.Command) (*registry.Client, error) {
+	opts := []registry.Option{
+		registry.WithPlainHTTP(c.StringSlice("plain-http")...),
+	}
+	if username := c.String("registry-username"); username != "" {
+		host := c.String("registry-host")
+		if host == "" {
+			return nil, errors.New("--registry-host is required with --registry-username")
+		}
+		opts = append(opts, registry.WithBasicAuth(host, username, c.String("registry-password")))
+	}
+	return registry.NewClient(opts...), nil
+}
@@ -0,0 +1,154 @@
+package drift
+
+import (
+	"sort"
+
+	"github.com/mdevilliers/org-scrounger/pkg/gh"
+	"github.com/mdevilliers/org-scrounger/pkg/mapping"
+	"github.com/mdevilliers/org-scrounger/pkg/util"
+)
+
+type (
+	// Environment is the images deployed to a named environment e.g. 'prod'
+	Environment struct {
+		Name   string
+		Images []mapping.Image
+	}
+	// Service is a repository, or an image that isn't mapped to one, deployed to environments
+	Service struct {
+		Name          string             `json:"name"`
+		Repo          *gh.RepositorySlim `json:"repo,omitempty"`
+		LatestVersion string             `json:"latest_version,omitempty"`
+		// Drifted is true if the environments run different versions of an image
+		Drifted bool `json:"drifted"`
+		// Missing are the environments the service isn't deployed to
+		Missing     []string     `json:"missing,omitempty"`
+		Deployments []Deployment `json:"deployments"`
+	}
+	// Deployment is the version of an image deployed to an environment
+	Deployment struct {
+		Environment string `json:"environment"`
+		Image       string `json:"image"`
+		Version     string `json:"version"`
+		// VersionsBehind is the number of released versions between the version and the latest version
+		VersionsBehind *int `json:"versions_behind,omitempty"`
+	}
+)
+
+// Compare aligns the images of the environments by their mapped repository, or by name
+// if the image isn't mapped, reporting the versions deployed to each environment.
+// Services are ordered by name and their deployments by the order of the environments.
+func Compare(environments []Environment) []Service {
+
+	services := map[string]*Service{}
+	order := map[string]int{}
+
+	for n, env := range environments {
+		order[env.Name] = n
+		for _, image := range env.Images {
+			key, name := image.Name, image.Name
+			if image.Repo != nil && image.Repo.URL != "" {
+				key, name = image.Repo.URL, repoName(*image.Repo)
+			}
+			s, found := services[key]
+			if !found {
+				s = &Service{Name: name, Repo: image.Repo}
+				services[key] = s
+			}
+			d := Deployment{Environment: env.Name, Image: image.Name, Version: image.Version}
+			if !containsDeployment(s.Deployments, d) {
+				s.Deployments = append(s.Deployments, d)
+			}
+		}
+	}
+
+	ret := []Service{}
+	for _, s := range services {
+		sort.SliceStable(s.Deployments, func(i, j int) bool {
+			a, b := s.Deployments[i], s.Deployments[j]
+			if a.Environment != b.Environment {
+				return order[a.Environment] < order[b.Environment]
+			}
+			if a.Image != b.Image {
+				return a.Image < b.Image
+			}
+			return a.Version < b.Version
+		})
+		s.Drifted = drifted(s.Deployments)
+		for _, env := range environments {
+			if !deployedTo(s.Deployments, env.Name) {
+				s.Missing = append(s.Missing, env.Name)
+			}
+		}
+		ret = append(ret, *s)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// Behind sets the latest version of the service, the highest semver tag ignoring pre-releases,
+// and how many released versions each deployment is behind it. Deployments of versions that
+// aren't semver are left unset.
+func (s *Service) Behind(tags []string) {
+
+	latest, found := util.LatestSemverTag(tags)
+	if !found {
+		return
+	}
+	s.LatestVersion = latest
+
+	for n := range s.Deployments {
+		if behind, ok := util.VersionsBehind(tags, latest, s.Deployments[n].Version); ok {
+			s.Deployments[n].VersionsBehind = &behind
+		}
+	}
+}
+
+// Owner returns the owner and name of the mapped repository
+func (s *Service) Owner() (string, string, bool) {
+	if s.Repo == nil {
+		return "", "", false
+	}
+	return gh.ParseRepoURL(s.Repo.URL)
+}
+
+// repoName returns 'owner/name' from the url of the repository falling back to its name
+func repoName(repo gh.RepositorySlim) string {
+	owner, name, ok := gh.ParseRepoURL(repo.URL)
+	if !ok {
+		return repo.Name
+	}
+	return owner + "/" + name
+}
+
+// drifted returns true if an image is deployed at more than one version
+func drifted(deployments []Deployment) bool {
+	versions := map[string]string{}
+	for _, d := range deployments {
+		if v, found := versions[d.Image]; found && v != d.Version {
+			return true
+		}
+		versions[d.Image] = d.Version
+	}
+	return false
+}
+
+func deployedTo(deployments []Deployment, environment string) bool {
+	for _, d := range deployments {
+		if d.Environment == environment {
+			return true
+		}
+	}
+	return false
+}
+
+func containsDeployment(deployments []Deployment, d Deployment) bool {
+	for _, existing := range deployments {
+		if existing == d {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,85 @@
+package drift
+
+import (
+	"testing"
+
+	"github.com/mdevilliers/org-scrounger/pkg/gh"
+	"github.com/mdevilliers/org-scrounger/pkg/mapping"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Compare(t *testing.T) {
+
+	web := &gh.RepositorySlim{Name: "web", URL: "https://github.com/org/web"}
+
+	environments := []Environment{
+		{Name: "dev", Images: []mapping.Image{
+			{Name: "web", Version: "1.2.0", Repo: web},
+			{Name: "web-migrations", Version: "1.2.0", Repo: web},
+			{Name: "redis", Version: "7.0"},
+			{Name: "debug", Version: "latest"},
+		}},
+		{Name: "prod", Images: []mapping.Image{
+			{Name: "web", Version: "1.0.0", Repo: web},
+			// the same image in another namespace
+			{Name: "web", Version: "1.0.0", Repo: web},
+			{Name: "web-migrations", Version: "1.0.0", Repo: web},
+			{Name: "redis", Version: "7.0"},
+		}},
+	}
+
+	services := Compare(environments)
+	require.Len(t, services, 3)
+
+	require.Equal(t, "debug", services[0].Name)
+	require.False(t, services[0].Drifted)
+	require.Equal(t, []string{"prod"}, services[0].Missing)
+
+	require.Equal(t, "org/web", services[1].Name)
+	require.True(t, services[1].Drifted)
+	require.Empty(t, services[1].Missing)
+	require.Equal(t, []Deployment{
+		{Environment: "dev", Image: "web", Version: "1.2.0"},
+		{Environment: "dev", Image: "web-migrations", Version: "1.2.0"},
+		{Environment: "prod", Image: "web", Version: "1.0.0"},
+		{Environment: "prod", Image: "web-migrations", Version: "1.0.0"},
+	}, services[1].Deployments)
+
+	owner, name, found := services[1].Owner()
+	require.True(t, found)
+	require.Equal(t, "org", owner)
+	require.Equal(t, "web", name)
+
+	require.Equal(t, "redis", services[2].Name)
+	require.False(t, services[2].Drifted)
+	_, _, found = services[2].Owner()
+	require.False(t, found)
+}
+
+func Test_Behind(t *testing.T) {
+
+	s := Service{Deployments: []Deployment{
+		{Environment: "dev", Version: "v1.2.0"},
+		{Environment: "staging", Version: "1.1.0@sha256:abc"},
+		{Environment: "prod", Version: "1.0.0"},
+		{Environment: "test", Version: "latest"},
+	}}
+	s.Behind([]string{"v1.2.0", "1.2.0", "v1.1.0", "v1.1.1-rc.1", "v1.0.0", "v2.0.0-beta", "nightly"})
+
+	require.Equal(t, "v1.2.0", s.LatestVersion)
+
+	behind := map[string]*int{}
+	for _, d := range s.Deployments {
+		behind[d.Environment] = d.VersionsBehind
+	}
+	require.Equal(t, 0, *behind["dev"])
+	require.Equal(t, 1, *behind["staging"])
+	require.Equal(t, 2, *behind["prod"])
+	require.Nil(t, behind["test"])
+
+	// no semver tags
+	s = Service{Deployments: []Deployment{{Environment: "dev", Version: "1.0.0"}}}
+	s.Behind([]string{"nightly"})
+	require.Empty(t, s.LatestVersion)
+	require.Nil(t, s.Deployments[0].VersionsBehind)
+}
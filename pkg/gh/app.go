@@ -0,0 +1,143 @@
+package gh
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// github rejects JWTs valid for more than 10 minutes
+	appJWTExpiry = 9 * time.Minute
+	// allow for clock drift between us and github
+	appJWTClockDrift = time.Minute
+)
+
+// appTokenSource mints installation access tokens for a Github App
+type appTokenSource struct {
+	ctx            context.Context
+	httpClient     *http.Client
+	restURL        string
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+}
+
+// NewClientFromGithubApp returns a configured client authenticating as an installation
+// of a Github App. Installation tokens are minted and refreshed as required.
+func NewClientFromGithubApp(ctx context.Context, appID, installationID int64, privateKey []byte, opts ...Option) (*Client, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return nil, err
+	}
+	src := &appTokenSource{
+		ctx:            ctx,
+		httpClient:     http.DefaultClient,
+		appID:          appID,
+		installationID: installationID,
+		key:            key,
+	}
+	identity := fmt.Sprintf("app:%d:%d", appID, installationID)
+	c := newClient(ctx, oauth2.ReuseTokenSource(nil, src), identity, opts...)
+	// tokens are minted lazily so it is safe to configure this after the client
+	src.restURL = c.endpoints.REST
+	return c, nil
+}
+
+// Token returns a freshly minted installation token
+func (a *appTokenSource) Token() (*oauth2.Token, error) {
+	jwt, err := a.jwt(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens", a.restURL, a.installationID)
+	req, err := http.NewRequestWithContext(a.ctx, http.MethodPost, url, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("error creating installation token request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := a.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error requesting installation token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("error requesting installation token for installation %d: %s", a.installationID, resp.Status)
+	}
+
+	var body struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("error decoding installation token: %w", err)
+	}
+	return &oauth2.Token{
+		AccessToken: body.Token,
+		TokenType:   "token",
+		Expiry:      body.ExpiresAt,
+	}, nil
+}
+
+// jwt returns a signed JSON Web Token identifying the app
+func (a *appTokenSource) jwt(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", fmt.Errorf("error marshalling jwt header: %w", err)
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iat": now.Add(-appJWTClockDrift).Unix(),
+		"exp": now.Add(appJWTExpiry).Unix(),
+		"iss": strconv.FormatInt(a.appID, 10),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error marshalling jwt claims: %w", err)
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+
+	hash := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("error signing jwt: %w", err)
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// parsePrivateKey parses a PEM encoded PKCS1 or PKCS8 RSA private key
+func parsePrivateKey(data []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("error decoding private key: no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("error parsing private key: not an RSA key")
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,63 @@
+package gh
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_AppTokenSource(t *testing.T) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	parsed, err := parsePrivateKey(pemKey)
+	require.Nil(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/app/installations/456/access_tokens", r.URL.Path)
+
+		jwt := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		bits := strings.Split(jwt, ".")
+		require.Len(t, bits, 3)
+
+		sig, err := base64.RawURLEncoding.DecodeString(bits[2])
+		require.Nil(t, err)
+		hash := sha256.Sum256([]byte(bits[0] + "." + bits[1]))
+		require.Nil(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, hash[:], sig))
+
+		claims, err := base64.RawURLEncoding.DecodeString(bits[1])
+		require.Nil(t, err)
+		require.Contains(t, string(claims), `"iss":"123"`)
+
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"token":"ghs_foo","expires_at":"2030-01-01T00:00:00Z"}`))
+	}))
+	defer server.Close()
+
+	src := &appTokenSource{
+		ctx:            context.Background(),
+		httpClient:     server.Client(),
+		restURL:        server.URL,
+		appID:          123,
+		installationID: 456,
+		key:            parsed,
+	}
+
+	token, err := src.Token()
+	require.Nil(t, err)
+	require.Equal(t, "ghs_foo", token.AccessToken)
+	require.Equal(t, 2030, token.Expiry.Year())
+}
@@ -0,0 +1,210 @@
+package gh
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const cacheFileExtension = ".json"
+
+type (
+	// Cache persists the responses of github queries on disk
+	// keyed by the query and its variables.
+	Cache struct {
+		dir string
+		ttl time.Duration
+	}
+	// CacheEntry is the on disk representation of a cached response
+	CacheEntry struct {
+		Key       string                 `json:"key"`
+		Query     string                 `json:"query"`
+		Variables map[string]interface{} `json:"variables"`
+		CreatedAt time.Time              `json:"created_at"`
+		ExpiresAt time.Time              `json:"expires_at"`
+		Data      json.RawMessage        `json:"data,omitempty"`
+	}
+)
+
+// DefaultCacheDir returns the users cache directory for scrng
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("error finding user cache directory: %w", err)
+	}
+	return filepath.Join(dir, "scrng", "gh"), nil
+}
+
+// NewCache returns a Cache storing entries in dir for the duration of the ttl
+func NewCache(dir string, ttl time.Duration) (*Cache, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("error creating cache directory %s: %w", dir, err)
+	}
+	return &Cache{
+		dir: dir,
+		ttl: ttl,
+	}, nil
+}
+
+// Dir returns the directory the Cache stores entries in
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Get loads the cached response for key into v returning true if
+// an unexpired entry was found
+func (c *Cache) Get(key string, v interface{}) (bool, error) {
+	entry, err := c.read(filepath.Join(c.dir, key+cacheFileExtension))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if entry.IsExpired() {
+		return false, nil
+	}
+	if err := json.Unmarshal(entry.Data, v); err != nil {
+		return false, fmt.Errorf("error unmarshalling cache entry %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// Set stores v against the key
+func (c *Cache) Set(key, query string, variables map[string]interface{}, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("error marshalling cache entry %s: %w", key, err)
+	}
+	now := time.Now()
+	entry := CacheEntry{
+		Key:       key,
+		Query:     query,
+		Variables: variables,
+		CreatedAt: now,
+		ExpiresAt: now.Add(c.ttl),
+		Data:      data,
+	}
+	b, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("error marshalling cache entry %s: %w", key, err)
+	}
+
+	// write then rename so concurrent readers never see a partial entry
+	f, err := os.CreateTemp(c.dir, key+"-*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating cache entry %s: %w", key, err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing cache entry %s: %w", key, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing cache entry %s: %w", key, err)
+	}
+	if err := os.Rename(f.Name(), filepath.Join(c.dir, key+cacheFileExtension)); err != nil {
+		return fmt.Errorf("error writing cache entry %s: %w", key, err)
+	}
+	return nil
+}
+
+// Entries returns all of the entries in the cache, without their data,
+// ordered by creation time
+func (c *Cache) Entries() ([]CacheEntry, error) {
+	files, err := c.files()
+	if err != nil {
+		return nil, err
+	}
+	all := []CacheEntry{}
+	for _, f := range files {
+		entry, err := c.read(f)
+		if err != nil {
+			return nil, err
+		}
+		entry.Data = nil
+		all = append(all, entry)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].CreatedAt.Before(all[j].CreatedAt)
+	})
+	return all, nil
+}
+
+// Purge deletes entries from the cache returning the number deleted.
+// If expiredOnly is true only the expired entries are deleted.
+func (c *Cache) Purge(expiredOnly bool) (int, error) {
+	files, err := c.files()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, f := range files {
+		if expiredOnly {
+			entry, err := c.read(f)
+			// unreadable entries are always purged
+			if err == nil && !entry.IsExpired() {
+				continue
+			}
+		}
+		if err := os.Remove(f); err != nil {
+			return n, fmt.Errorf("error deleting cache entry %s: %w", f, err)
+		}
+		n++
+	}
+	return n, nil
+}
+
+// IsExpired returns true if the entry has outlived its ttl
+func (e CacheEntry) IsExpired() bool {
+	return time.Now().After(e.ExpiresAt)
+}
+
+func (c *Cache) files() ([]string, error) {
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return nil, fmt.Errorf("error reading cache directory %s: %w", c.dir, err)
+	}
+	all := []string{}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), cacheFileExtension) {
+			continue
+		}
+		all = append(all, filepath.Join(c.dir, e.Name()))
+	}
+	return all, nil
+}
+
+func (c *Cache) read(path string) (CacheEntry, error) {
+	entry := CacheEntry{}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return entry, err
+	}
+	if err := json.Unmarshal(b, &entry); err != nil {
+		return entry, fmt.Errorf("error unmarshalling cache entry %s: %w", path, err)
+	}
+	return entry, nil
+}
+
+// cacheKey returns a stable key for the query and its variables.
+// The type of the query is included as the fields requested are
+// defined by its struct tags. The endpoint and identity are included
+// as the repos visible differ between github instances and credentials.
+func cacheKey(endpoint, identity, name string, q interface{}, variables map[string]interface{}) (string, error) {
+	vars, err := json.Marshal(variables)
+	if err != nil {
+		return "", fmt.Errorf("error marshalling variables: %w", err)
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n%T\n%s", endpoint, identity, name, q, vars)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,119 @@
+package gh
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CacheRoundTrip(t *testing.T) {
+
+	cache, err := NewCache(t.TempDir(), time.Hour)
+	require.Nil(t, err)
+
+	type query struct {
+		RateLimit  RateLimit `json:"rate_limit"`
+		Repository struct {
+			Name githubv4.String `json:"name"`
+		} `json:"repository"`
+	}
+
+	variables := map[string]interface{}{
+		"name":   githubv4.String("foo"),
+		"cursor": (*githubv4.String)(nil),
+	}
+
+	q := query{}
+	key, err := cacheKey("https://api.github.com/graphql", "token:a", "foo", &q, variables)
+	require.Nil(t, err)
+
+	found, err := cache.Get(key, &q)
+	require.Nil(t, err)
+	require.False(t, found)
+
+	q.Repository.Name = "bar"
+	q.RateLimit.Cost = 1
+	require.Nil(t, cache.Set(key, "foo", variables, &q))
+
+	cached := query{}
+	found, err = cache.Get(key, &cached)
+	require.Nil(t, err)
+	require.True(t, found)
+	require.Equal(t, q, cached)
+
+	entries, err := cache.Entries()
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, "foo", entries[0].Query)
+
+	// a different endpoint or identity gives a different key
+	other, err := cacheKey("https://github.example.com/api/graphql", "token:a", "foo", &q, variables)
+	require.Nil(t, err)
+	require.NotEqual(t, key, other)
+
+	other, err = cacheKey("https://api.github.com/graphql", "token:b", "foo", &q, variables)
+	require.Nil(t, err)
+	require.NotEqual(t, key, other)
+
+	// different variables give a different key
+	variables["name"] = githubv4.String("bar")
+	other, err = cacheKey("https://api.github.com/graphql", "token:a", "foo", &q, variables)
+	require.Nil(t, err)
+	require.NotEqual(t, key, other)
+
+	n, err := cache.Purge(true)
+	require.Nil(t, err)
+	require.Equal(t, 0, n)
+
+	n, err = cache.Purge(false)
+	require.Nil(t, err)
+	require.Equal(t, 1, n)
+}
+
+func Test_CacheExpiry(t *testing.T) {
+
+	cache, err := NewCache(t.TempDir(), -time.Second)
+	require.Nil(t, err)
+
+	require.Nil(t, cache.Set("key", "foo", nil, RateLimit{Cost: 1}))
+
+	rl := RateLimit{}
+	found, err := cache.Get("key", &rl)
+	require.Nil(t, err)
+	require.False(t, found)
+
+	n, err := cache.Purge(true)
+	require.Nil(t, err)
+	require.Equal(t, 1, n)
+}
+
+func Test_CacheIsNotSharedBetweenIdentities(t *testing.T) {
+
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.Header.Get("Authorization")]++
+		_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1},"repository":{"refs":{
+			"nodes":[{"name":"v1.0.0"}],"pageInfo":{"hasNextPage":false}}}}}`))
+	}))
+	defer server.Close()
+
+	endpoints, err := ParseAPIURL(server.URL)
+	require.Nil(t, err)
+
+	cache, err := NewCache(t.TempDir(), time.Hour)
+	require.Nil(t, err)
+
+	ctx := context.Background()
+	for _, token := range []string{"a", "a", "b"} {
+		client := NewClientFromGithubPAT(ctx, token, WithEndpoints(endpoints), WithCache(cache, false))
+		_, _, err := client.GetTags(ctx, "org", "foo")
+		require.Nil(t, err)
+	}
+
+	require.Equal(t, map[string]int{"Bearer a": 1, "Bearer b": 1}, requests)
+}
@@ -2,8 +2,12 @@ package gh
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
+	"time"
 
+	"github.com/rs/zerolog/log"
 	"github.com/shurcooL/githubv4"
 	"golang.org/x/oauth2"
 )
@@ -14,15 +18,32 @@ type (
 		Oid string `json:"oid"`
 	}
 	Commit struct {
-		Message        string `json:"message"`
-		AbbreviatedOid string `json:"abbreviated_oid"`
-		Oid            string `json:"oid"`
-		URL            string `json:"url"`
+		Message        string    `json:"message"`
+		AbbreviatedOid string    `json:"abbreviated_oid"`
+		Oid            string    `json:"oid"`
+		URL            string    `json:"url"`
+		Author         string    `json:"author"`
+		CommittedDate  time.Time `json:"committed_date"`
+	}
+	// Release is the last release of a repository
+	Release struct {
+		Tag         string    `json:"tag"`
+		Name        string    `json:"name"`
+		PublishedAt time.Time `json:"published_at"`
+		// Source is 'release' for a Github Release or 'tag' if derived from a semver tag
+		Source string `json:"source"`
 	}
 	UnreleasedCommits struct {
-		Commits []Commit `json:"commits"`
-		LastTag Tag      `json:"last_tag"`
-		Summary string   `json:"summary"`
+		Commits     []Commit `json:"commits"`
+		LastTag     Tag      `json:"last_tag"`
+		LastRelease *Release `json:"last_release,omitempty"`
+		// AheadBy is the number of commits on the branch since the last release
+		AheadBy  int      `json:"ahead_by"`
+		BehindBy int      `json:"behind_by"`
+		Authors  []string `json:"authors"`
+		Summary  string   `json:"summary"`
+		// Branch is the name of the branch inspected
+		Branch string `json:"branch"`
 	}
 	RepositorySlim struct {
 		Name       string    `json:"name"`
@@ -32,24 +53,160 @@ type (
 		Languages  Languages `json:"languages"`
 	}
 
-	client struct {
-		graph *githubv4.Client
+	// Client queries the github GraphQL API
+	Client struct {
+		graph     *githubv4.Client
+		endpoints Endpoints
+		budget    *budget
+		cache     *Cache
+		// identity fingerprints the credentials so cached responses aren't shared between them
+		identity string
+		// refresh ignores any cached responses but still updates the cache
+		refresh    bool
+		maxRetries int
+		backoff    time.Duration
+		maxPages   int
 	}
 )
 
+const (
+	defaultMaxRetries = 3
+	defaultBackoff    = time.Second
+)
+
+// Option can be supplied that override the default clients properties
+type Option func(c *Client)
+
+// WithCache stores query responses in the supplied Cache.
+// If refresh is true cached responses are ignored but the cache is still updated.
+func WithCache(cache *Cache, refresh bool) Option {
+	return func(c *Client) {
+		c.cache = cache
+		c.refresh = refresh
+	}
+}
+
+// WithoutCache never uses or updates the cache e.g. when acting on the responses
+func WithoutCache() Option {
+	return func(c *Client) {
+		c.cache = nil
+	}
+}
+
+// WithRateLimitThreshold pauses all queries until the rate limit
+// resets when the remaining quota drops below the threshold.
+// A threshold of 0 disables pausing.
+func WithRateLimitThreshold(threshold int) Option {
+	return func(c *Client) {
+		c.budget.threshold = threshold
+	}
+}
+
+// WithRetries sets the maximum number of retries for secondary rate limit
+// and server errors along with the initial backoff, which doubles on each retry.
+func WithRetries(maxRetries int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = maxRetries
+		c.backoff = backoff
+	}
+}
+
+// WithEndpoints targets a github instance other than github.com
+// e.g. a Github Enterprise Server
+func WithEndpoints(e Endpoints) Option {
+	return func(c *Client) {
+		c.endpoints = e
+	}
+}
+
 // NewClientFromEnv returns a configured client using the env var GITHUB_TOKEN
-func NewClientFromEnv(ctx context.Context) *client {
+func NewClientFromEnv(ctx context.Context, opts ...Option) *Client {
 	token := os.Getenv("GITHUB_TOKEN")
-	return NewClientFromGithubPAT(ctx, token)
+	return NewClientFromGithubPAT(ctx, token, opts...)
 }
 
 // NewClientFromGithubPAT returns a configured client using the supplied Github PAT
-func NewClientFromGithubPAT(ctx context.Context, token string) *client {
+func NewClientFromGithubPAT(ctx context.Context, token string, opts ...Option) *Client {
 	src := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: token},
 	)
+	h := sha256.Sum256([]byte(token))
+	return newClient(ctx, src, "token:"+hex.EncodeToString(h[:]), opts...)
+}
+
+func newClient(ctx context.Context, src oauth2.TokenSource, identity string, opts ...Option) *Client {
+	c := &Client{
+		identity:   identity,
+		endpoints:  defaultEndpoints(),
+		budget:     &budget{},
+		maxRetries: defaultMaxRetries,
+		backoff:    defaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
 	httpClient := oauth2.NewClient(ctx, src)
-	return &client{
-		graph: githubv4.NewClient(httpClient),
+	httpClient.Transport = &retryTransport{
+		next:       httpClient.Transport,
+		budget:     c.budget,
+		maxRetries: c.maxRetries,
+		backoff:    c.backoff,
+	}
+	c.graph = githubv4.NewEnterpriseClient(c.endpoints.GraphQL, httpClient)
+	return c
+}
+
+// Endpoints returns the URLs of the github instance the client targets
+func (c *Client) Endpoints() Endpoints {
+	return c.endpoints
+}
+
+// RateLimit returns the rate limit as of the last query along
+// with the total cost of all of the queries made by the client
+func (c *Client) RateLimit() RateLimit {
+	return c.budget.current()
+}
+
+// query executes the named query, using the cache if one is configured
+func (c *Client) query(ctx context.Context, name string, q interface{}, variables map[string]interface{}) error {
+	if c.cache == nil {
+		return c.graphQuery(ctx, q, variables)
+	}
+
+	key, err := cacheKey(c.endpoints.GraphQL, c.identity, name, q, variables)
+	if err != nil {
+		return err
+	}
+
+	if !c.refresh {
+		found, err := c.cache.Get(key, q)
+		if err != nil {
+			// a broken cache entry shouldn't stop us talking to github
+			log.Warn().Err(err).Str("query", name).Msg("error reading from cache")
+		}
+		if found {
+			return nil
+		}
+	}
+
+	if err := c.graphQuery(ctx, q, variables); err != nil {
+		return err
+	}
+
+	if err := c.cache.Set(key, name, variables, q); err != nil {
+		log.Warn().Err(err).Str("query", name).Msg("error writing to cache")
+	}
+	return nil
+}
+
+// graphQuery executes the query against github keeping track of the rate limit
+func (c *Client) graphQuery(ctx context.Context, q interface{}, variables map[string]interface{}) error {
+	if err := c.graph.Query(ctx, q, variables); err != nil {
+		return err
+	}
+	if rl, ok := rateLimitOf(q); ok {
+		c.budget.observe(rl)
 	}
+	return nil
 }
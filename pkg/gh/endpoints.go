@@ -0,0 +1,78 @@
+package gh
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	DefaultAPIURL = "https://api.github.com"
+
+	publicAPIHost    = "api.github.com"
+	publicWebURL     = "https://github.com"
+	enterpriseREST   = "/api/v3"
+	enterpriseGraph  = "/api/graphql"
+	publicGraphQLURL = DefaultAPIURL + "/graphql"
+)
+
+// Endpoints are the URLs of a github instance
+type Endpoints struct {
+	// REST is the root of the REST API
+	REST string
+	// GraphQL is the GraphQL API endpoint
+	GraphQL string
+	// Web is the root of the web UI e.g. https://github.com
+	Web string
+}
+
+// ParseAPIURL returns the Endpoints for the github instance
+// with the REST API root of apiURL e.g. https://api.github.com
+// or https://github.example.com/api/v3 for a Github Enterprise Server
+func ParseAPIURL(apiURL string) (Endpoints, error) {
+	u, err := url.Parse(strings.TrimSuffix(apiURL, "/"))
+	if err != nil {
+		return Endpoints{}, fmt.Errorf("error parsing github url %s: %w", apiURL, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return Endpoints{}, fmt.Errorf("error parsing github url %s: expected an absolute URL", apiURL)
+	}
+
+	if u.Host == publicAPIHost {
+		return defaultEndpoints(), nil
+	}
+
+	root := fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, strings.TrimSuffix(u.Path, enterpriseREST))
+	return Endpoints{
+		REST:    root + enterpriseREST,
+		GraphQL: root + enterpriseGraph,
+		Web:     root,
+	}, nil
+}
+
+// RepoURL returns the web URL for a repository
+func (e Endpoints) RepoURL(owner, repo string) string {
+	return fmt.Sprintf("%s/%s/%s", e.Web, owner, repo)
+}
+
+func defaultEndpoints() Endpoints {
+	return Endpoints{
+		REST:    DefaultAPIURL,
+		GraphQL: publicGraphQLURL,
+		Web:     publicWebURL,
+	}
+}
+
+// ParseRepoURL returns the owner and name of the repository from
+// its web URL e.g. https://github.com/owner/repo
+func ParseRepoURL(repoURL string) (string, string, bool) {
+	u, err := url.Parse(repoURL)
+	if err != nil {
+		return "", "", false
+	}
+	parts := strings.Split(strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git"), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" { //nolint: gomnd
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
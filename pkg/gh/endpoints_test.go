@@ -0,0 +1,94 @@
+package gh
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseAPIURL(t *testing.T) {
+
+	tests := []struct {
+		in       string
+		expected Endpoints
+	}{
+		{
+			in:       "https://api.github.com",
+			expected: Endpoints{REST: "https://api.github.com", GraphQL: "https://api.github.com/graphql", Web: "https://github.com"},
+		},
+		{
+			in:       "https://github.example.com/api/v3/",
+			expected: Endpoints{REST: "https://github.example.com/api/v3", GraphQL: "https://github.example.com/api/graphql", Web: "https://github.example.com"},
+		},
+		{
+			in:       "https://github.example.com",
+			expected: Endpoints{REST: "https://github.example.com/api/v3", GraphQL: "https://github.example.com/api/graphql", Web: "https://github.example.com"},
+		},
+	}
+
+	for _, test := range tests {
+		e, err := ParseAPIURL(test.in)
+		require.Nil(t, err)
+		require.Equal(t, test.expected, e)
+	}
+
+	_, err := ParseAPIURL("github.example.com")
+	require.NotNil(t, err)
+}
+
+func Test_EnterpriseClient(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/graphql", r.URL.Path)
+		require.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+
+		var body struct {
+			Variables map[string]string `json:"variables"`
+		}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+		require.Equal(t, "foo", body.Variables["name"])
+
+		_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1},"repository":{"name":"foo","url":"` +
+			"http://" + r.Host + `/org/foo","isArchived":false}}}`))
+	}))
+	defer server.Close()
+
+	endpoints, err := ParseAPIURL(server.URL + "/api/v3")
+	require.Nil(t, err)
+
+	client := NewClientFromGithubPAT(context.Background(), "token", WithEndpoints(endpoints))
+
+	repo, rl, err := client.GetRepoByName(context.Background(), "org", "foo")
+	require.Nil(t, err)
+	require.Equal(t, "foo", repo.Name)
+	require.Equal(t, endpoints.RepoURL("org", "foo"), repo.URL)
+	require.Equal(t, 1, int(rl.Cost))
+	require.Equal(t, 1, int(client.RateLimit().Cost))
+}
+
+func Test_ParseRepoURL(t *testing.T) {
+
+	tests := []struct {
+		in    string
+		owner string
+		name  string
+		found bool
+	}{
+		{in: "https://github.com/org/repo", owner: "org", name: "repo", found: true},
+		{in: "https://github.example.com/org/repo.git/", owner: "org", name: "repo", found: true},
+		{in: "https://github.com/org", found: false},
+		{in: "https://github.com/org/repo/tree/main", found: false},
+		{in: "", found: false},
+	}
+
+	for _, test := range tests {
+		owner, name, found := ParseRepoURL(test.in)
+		require.Equal(t, test.found, found, test.in)
+		require.Equal(t, test.owner, owner)
+		require.Equal(t, test.name, name)
+	}
+}
@@ -7,7 +7,7 @@ import (
 	"github.com/shurcooL/githubv4"
 )
 
-func (c *client) GetRepoByName(ctx context.Context, owner, repo string) (RepositorySlim, RateLimit, error) {
+func (c *Client) GetRepoByName(ctx context.Context, owner, repo string) (RepositorySlim, RateLimit, error) {
 
 	var query struct {
 		RateLimit  RateLimit `json:"rate_limit"`
@@ -31,7 +31,7 @@ func (c *client) GetRepoByName(ctx context.Context, owner, repo string) (Reposit
 		"owner": githubv4.String(owner),
 	}
 
-	if err := c.graph.Query(ctx, &query, variables); err != nil {
+	if err := c.query(ctx, "GetRepoByName", &query, variables); err != nil {
 		return RepositorySlim{}, RateLimit{}, fmt.Errorf("error querying github: %w", err)
 	}
 	r := query.Repository
@@ -9,37 +9,56 @@ import (
 )
 
 type Repository struct {
-	Name       githubv4.String  `json:"name"`
-	URL        githubv4.String  `json:"url"`
-	IsArchived githubv4.Boolean `json:"is_archived"`
-	Languages  Languages        `json:"languages" graphql:"languages(first:10)"`
-	Ref        struct {
-		Target struct {
-			Commit struct {
-				Message           githubv4.String `json:"message"`
-				StatusCheckRollup struct {
-					State    githubv4.String `json:"state"`
-					Contexts struct {
-						Nodes []struct {
-							StatusContext `graphql:"... on StatusContext" json:"status_context,omitempty"`
-							CheckRun      `graphql:"... on CheckRun" json:"check_run,omitempty"`
-						} `json:"nodes"`
-					} `json:"contexts" graphql:"contexts(first:20)"`
-				} `json:"statusCheckRollup"`
-			} `graphql:"... on Commit" json:"commit"`
-		} `json:"target"`
-	} `graphql:"ref(qualifiedName: \"main\" )" json:"ref"`
-	RepositoryTopics struct {
-		Nodes []struct {
-			Topic struct {
-				Name githubv4.String `json:"name"`
-			} `json:"topic"`
-		} `json:"nodes"`
-	} `graphql:"repositoryTopics(first:10)" json:"repository_topics"`
-	PullRequests        `graphql:"pullRequests(last:30, states:[OPEN])" json:"pull_requests"`
+	Name       githubv4.String     `json:"name"`
+	URL        githubv4.String     `json:"url"`
+	IsArchived githubv4.Boolean    `json:"is_archived"`
+	Languages  LanguagesConnection `json:"languages" graphql:"languages(first:10)"`
+	// Ref is the branch inspected, by default the repository's default branch
+	Ref                 Ref              `graphql:"ref: defaultBranchRef @skip(if: $hasBranch)" json:"ref"`
+	RepositoryTopics    RepositoryTopics `graphql:"repositoryTopics(first:10)" json:"repository_topics"`
+	PullRequests        `graphql:"pullRequests(first:30, states:[OPEN], orderBy:{field:CREATED_AT, direction:DESC})" json:"pull_requests"`
 	VulnerabilityAlerts `graphql:"vulnerabilityAlerts(first:100, states:[OPEN])" json:"vulnerability_alerts"`
 }
 
+// PageInfo is used to paginate through a connection
+type PageInfo struct {
+	HasNextPage githubv4.Boolean `json:"has_next_page"`
+	EndCursor   githubv4.String  `json:"end_cursor"`
+}
+
+type RepositoryTopics struct {
+	Nodes []struct {
+		Topic struct {
+			Name githubv4.String `json:"name"`
+		} `json:"topic"`
+	} `json:"nodes"`
+	PageInfo PageInfo `json:"page_info"`
+}
+
+// Ref is the head of a branch along with its CI status
+type Ref struct {
+	Name   githubv4.String `json:"name"`
+	Target struct {
+		Commit struct {
+			Oid               githubv4.GitObjectID `json:"oid"`
+			Message           githubv4.String      `json:"message"`
+			StatusCheckRollup struct {
+				State    githubv4.String     `json:"state"`
+				Contexts StatusCheckContexts `json:"contexts" graphql:"contexts(first:20)"`
+			} `json:"statusCheckRollup"`
+		} `graphql:"... on Commit" json:"commit"`
+	} `json:"target"`
+}
+
+// StatusCheckContexts are the individual checks that make up a commits status
+type StatusCheckContexts struct {
+	Nodes []struct {
+		StatusContext `graphql:"... on StatusContext" json:"status_context,omitempty"`
+		CheckRun      `graphql:"... on CheckRun" json:"check_run,omitempty"`
+	} `json:"nodes"`
+	PageInfo PageInfo `json:"page_info"`
+}
+
 type Languages struct {
 	Edges []struct {
 		Size githubv4.Int `json:"size"`
@@ -49,6 +68,12 @@ type Languages struct {
 	} `json:"nodes"`
 }
 
+// LanguagesConnection is a paginated set of Languages
+type LanguagesConnection struct {
+	Languages
+	PageInfo PageInfo `json:"page_info"`
+}
+
 func (l Languages) Top() string {
 
 	if len(l.Nodes) == 0 {
@@ -100,22 +125,35 @@ func (s StatusContext) IsEmpty() bool {
 	return s.State == ""
 }
 
+// IsMainGreen returns true if the CI status of the inspected branch is successful
 func (r Repository) IsMainGreen() bool {
 	return string(r.Ref.Target.Commit.StatusCheckRollup.State) == "SUCCESS"
 }
 
 type PullRequests struct {
-	Nodes []PullRequest `json:"nodes"`
+	Nodes    []PullRequest `json:"nodes"`
+	PageInfo PageInfo      `json:"page_info"`
 }
 
 type PullRequest struct {
-	Title      githubv4.String   `json:"title"`
-	State      githubv4.String   `json:"state"`
-	Mergeable  githubv4.String   `json:"mergeable"`
-	CreatedAt  githubv4.DateTime `json:"created_at"`
-	URL        githubv4.String   `json:"url"`
-	IsDraft    githubv4.Boolean  `json:"is_draft"`
-	Body       githubv4.String   `json:"body"`
+	ID        githubv4.ID       `json:"id"`
+	Number    githubv4.Int      `json:"number"`
+	Title     githubv4.String   `json:"title"`
+	State     githubv4.String   `json:"state"`
+	Mergeable githubv4.String   `json:"mergeable"`
+	CreatedAt githubv4.DateTime `json:"created_at"`
+	URL       githubv4.String   `json:"url"`
+	IsDraft   githubv4.Boolean  `json:"is_draft"`
+	Body      githubv4.String   `json:"body"`
+	// HeadRefOid is the commit at the head of the pull request
+	HeadRefOid githubv4.GitObjectID `json:"head_ref_oid"`
+	// ReviewDecision is one of APPROVED, CHANGES_REQUESTED or REVIEW_REQUIRED
+	ReviewDecision githubv4.String `json:"review_decision"`
+	Labels         struct {
+		Nodes []struct {
+			Name githubv4.String `json:"name"`
+		} `json:"nodes"`
+	} `graphql:"labels(first:20)" json:"labels"`
 	Repository struct {
 		Name githubv4.String `json:"name"`
 	} `json:"repository"`
@@ -131,26 +169,47 @@ type PullRequest struct {
 			} `json:"commit"`
 		} `json:"nodes"`
 	} `graphql:"commits(last:1)" json:"commits"`
-	Comments struct {
-		TotalCount githubv4.Int `json:"total_count"`
-		Nodes      []struct {
-			Body   githubv4.String `json:"body"`
-			Author struct {
-				Login githubv4.String `json:"login"`
-			} `json:"author"`
-		} `json:"nodes"`
-	} `graphql:"comments(first:100)" json:"comments"`
+	Comments Comments `graphql:"comments(first:100)" json:"comments"`
+}
+
+type Comments struct {
+	TotalCount githubv4.Int `json:"total_count"`
+	Nodes      []struct {
+		Body   githubv4.String `json:"body"`
+		Author struct {
+			Login githubv4.String `json:"login"`
+		} `json:"author"`
+	} `json:"nodes"`
+	PageInfo PageInfo `json:"page_info"`
 }
 
 func (p PullRequest) IsMergable() bool {
 	return p.Mergeable == "MERGEABLE"
 }
 func (p PullRequest) LastCommitBuilds() bool {
-	return p.Commits.Nodes[0].Commit.StatusCheckRollup.State == "SUCCESS"
+	return p.LastCommitState() == "SUCCESS"
+}
+
+// LastCommitState returns the CI state of the last commit or an empty string
+func (p PullRequest) LastCommitState() string {
+	if len(p.Commits.Nodes) == 0 {
+		return ""
+	}
+	return string(p.Commits.Nodes[0].Commit.StatusCheckRollup.State)
+}
+
+// LabelNames returns the names of the labels on the pull request
+func (p PullRequest) LabelNames() []string {
+	ret := []string{}
+	for _, l := range p.Labels.Nodes {
+		ret = append(ret, string(l.Name))
+	}
+	return ret
 }
 
 type VulnerabilityAlerts struct {
-	Edges []VulnerabilityAlertsEdge `json:"edges"`
+	Edges    []VulnerabilityAlertsEdge `json:"edges"`
+	PageInfo PageInfo                  `json:"page_info"`
 }
 
 type VulnerabilityAlertsEdge struct {
@@ -167,7 +226,14 @@ type VulnerabilityAlertsEdge struct {
 				Ecosystem githubv4.String `json:"ecosystem"`
 			} `json:"package"`
 			Advisory struct {
+				GhsaID      githubv4.String `json:"ghsa_id"`
+				Summary     githubv4.String `json:"summary"`
 				Description githubv4.String `json:"description"`
+				Permalink   githubv4.String `json:"permalink"`
+				Identifiers []struct {
+					Type  githubv4.String `json:"type"`
+					Value githubv4.String `json:"value"`
+				} `json:"identifiers"`
 			} `json:"advisory"`
 			FirstPatchedVersion struct {
 				Identifier githubv4.String `json:"identifier"`
@@ -176,20 +242,42 @@ type VulnerabilityAlertsEdge struct {
 	} `json:"node"`
 }
 
-func (c *client) GetRepoDetails(ctx context.Context, owner, reponame string) (Repository, RateLimit, error) {
+// Branch returns the name of the inspected branch
+func (r Repository) Branch() string {
+	return string(r.Ref.Name)
+}
+
+// GetRepoDetails returns the details of a repository inspecting the branch
+// or the repository's default branch if branch is empty
+func (c *Client) GetRepoDetails(ctx context.Context, owner, reponame, branch string) (Repository, RateLimit, error) {
 
 	var query struct {
-		Repository `graphql:"repository(owner:$owner, name:$name)" json:"repository"`
-		RateLimit  RateLimit `json:"rate_limit"`
+		Repository struct {
+			Repository
+			// BranchRef is populated instead of Ref when a branch is specified
+			BranchRef Ref `graphql:"branchRef: ref(qualifiedName: $branch) @include(if: $hasBranch)" json:"branch_ref"`
+		} `graphql:"repository(owner:$owner, name:$name)" json:"repository"`
+		RateLimit RateLimit `json:"rate_limit"`
 	}
 
 	variables := map[string]interface{}{
-		"owner": githubv4.String(owner),
-		"name":  githubv4.String(reponame),
+		"owner":     githubv4.String(owner),
+		"name":      githubv4.String(reponame),
+		"branch":    githubv4.String(branch),
+		"hasBranch": githubv4.Boolean(branch != ""),
 	}
 
-	if err := c.graph.Query(ctx, &query, variables); err != nil {
+	if err := c.query(ctx, "GetRepoDetails", &query, variables); err != nil {
 		return Repository{}, query.RateLimit, fmt.Errorf("error querying repo details of %s/%s: %w", owner, reponame, err)
 	}
-	return query.Repository, query.RateLimit, nil
+	repo := query.Repository.Repository
+	if branch != "" {
+		repo.Ref = query.Repository.BranchRef
+	}
+
+	rl, err := c.paginateRepository(ctx, owner, reponame, &repo, query.RateLimit)
+	if err != nil {
+		return Repository{}, rl, fmt.Errorf("error paginating repo details of %s/%s: %w", owner, reponame, err)
+	}
+	return repo, rl, nil
 }
@@ -0,0 +1,54 @@
+package gh
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// GetTags returns the names of the tags of the repository, most recently committed first
+func (c *Client) GetTags(ctx context.Context, owner, reponame string) ([]string, RateLimit, error) {
+
+	type tags struct {
+		RateLimit  RateLimit `json:"rate_limit"`
+		Repository struct {
+			Refs struct {
+				Nodes []struct {
+					Name githubv4.String `json:"name"`
+				} `json:"nodes"`
+				PageInfo PageInfo `json:"page_info"`
+			} `graphql:"refs(first:100, after:$cursor, refPrefix: \"refs/tags/\", orderBy: {field: TAG_COMMIT_DATE, direction: DESC})" json:"refs"` //nolint: lll
+		} `graphql:"repository(owner:$owner, name:$name)" json:"repository"`
+	}
+
+	ret := []string{}
+
+	fetch := func(cursor *githubv4.String) (PageInfo, RateLimit, error) {
+		var query tags
+		variables := map[string]interface{}{
+			"owner":  githubv4.String(owner),
+			"name":   githubv4.String(reponame),
+			"cursor": cursor,
+		}
+		if err := c.query(ctx, "GetTags", &query, variables); err != nil {
+			return PageInfo{}, query.RateLimit, fmt.Errorf("error querying github: %w", err)
+		}
+		for _, t := range query.Repository.Refs.Nodes {
+			ret = append(ret, string(t.Name))
+		}
+		return query.Repository.Refs.PageInfo, query.RateLimit, nil
+	}
+
+	pageInfo, rl, err := fetch(nil)
+	if err != nil {
+		return nil, rl, err
+	}
+	rl, err = c.paginate(pageInfo, rl, func(cursor githubv4.String) (PageInfo, RateLimit, error) {
+		return fetch(&cursor)
+	})
+	if err != nil {
+		return nil, rl, err
+	}
+	return ret, rl, nil
+}
@@ -0,0 +1,40 @@
+package gh
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetTagsPaginates(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+
+		if body.Variables["cursor"] == nil {
+			_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1},"repository":{"refs":{
+				"nodes":[{"name":"v1.1.0"},{"name":"v1.0.0"}],"pageInfo":{"hasNextPage":true,"endCursor":"next"}}}}}`))
+			return
+		}
+		require.Equal(t, "next", body.Variables["cursor"])
+		_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1},"repository":{"refs":{
+			"nodes":[{"name":"v0.1.0"}],"pageInfo":{"hasNextPage":false}}}}}`))
+	}))
+	defer server.Close()
+
+	endpoints, err := ParseAPIURL(server.URL)
+	require.Nil(t, err)
+
+	client := NewClientFromGithubPAT(context.Background(), "token", WithEndpoints(endpoints))
+	tags, rl, err := client.GetTags(context.Background(), "org", "foo")
+	require.Nil(t, err)
+	require.Equal(t, 2, int(rl.Cost))
+	require.Equal(t, []string{"v1.1.0", "v1.0.0", "v0.1.0"}, tags)
+}
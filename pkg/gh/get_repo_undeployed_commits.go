@@ -0,0 +1,47 @@
+package gh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// ErrRevisionNotCompared is returned when github can't compare a revision with the
+// default branch e.g. the commit is unknown or was lost in a force push
+var ErrRevisionNotCompared = errors.New("revision can't be compared with the default branch")
+
+// GetUndeployedCommits returns the number of commits on the default branch of the
+// repository that aren't in revision e.g. the commit a deployed image was built from
+func (c *Client) GetUndeployedCommits(ctx context.Context, owner, reponame, revision string) (int, RateLimit, error) {
+
+	var query struct {
+		RateLimit  RateLimit `json:"rate_limit"`
+		Repository struct {
+			DefaultBranchRef *struct {
+				Name    githubv4.String `json:"name"`
+				Compare *struct {
+					AheadBy  githubv4.Int    `json:"ahead_by"`
+					BehindBy githubv4.Int    `json:"behind_by"`
+					Status   githubv4.String `json:"status"`
+				} `graphql:"compare(headRef:$revision)" json:"compare"`
+			} `json:"default_branch_ref"`
+		} `graphql:"repository(owner:$owner, name:$name)" json:"repository"`
+	}
+	variables := map[string]interface{}{
+		"owner":    githubv4.String(owner),
+		"name":     githubv4.String(reponame),
+		"revision": githubv4.String(revision),
+	}
+
+	if err := c.query(ctx, "GetUndeployedCommits", &query, variables); err != nil {
+		return 0, query.RateLimit, fmt.Errorf("error querying github: %w", err)
+	}
+	branch := query.Repository.DefaultBranchRef
+	if branch == nil || branch.Compare == nil {
+		return 0, query.RateLimit, fmt.Errorf("error comparing %s/%s@%s: %w", owner, reponame, revision, ErrRevisionNotCompared)
+	}
+	// the commits the default branch has that the revision doesn't
+	return int(branch.Compare.BehindBy), query.RateLimit, nil
+}
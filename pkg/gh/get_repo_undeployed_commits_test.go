@@ -0,0 +1,60 @@
+package gh
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetUndeployedCommits(t *testing.T) {
+	testCases := []struct {
+		desc       string
+		repository string
+		expected   int
+		err        error
+	}{
+		{
+			desc:       "behind",
+			repository: `{"defaultBranchRef":{"name":"main","compare":{"aheadBy":0,"behindBy":5,"status":"BEHIND"}}}`,
+			expected:   5,
+		},
+		{
+			desc:       "unknown revision",
+			repository: `{"defaultBranchRef":{"name":"main","compare":null}}`,
+			err:        ErrRevisionNotCompared,
+		},
+		{
+			desc:       "no default branch",
+			repository: `{"defaultBranchRef":null}`,
+			err:        ErrRevisionNotCompared,
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body struct {
+					Variables map[string]interface{} `json:"variables"`
+				}
+				require.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+				require.Equal(t, "abc123", body.Variables["revision"])
+
+				_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1},"repository":` + tC.repository + `}}`))
+			}))
+			defer server.Close()
+
+			endpoints, err := ParseAPIURL(server.URL)
+			require.Nil(t, err)
+
+			client := NewClientFromGithubPAT(context.Background(), "token", WithEndpoints(endpoints))
+			count, rl, err := client.GetUndeployedCommits(context.Background(), "org", "foo", "abc123")
+			require.ErrorIs(t, err, tC.err)
+			require.Equal(t, 1, int(rl.Cost))
+			require.Equal(t, tC.expected, count)
+		})
+	}
+}
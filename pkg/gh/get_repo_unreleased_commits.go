@@ -2,86 +2,276 @@ package gh
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"strings"
 
+	"github.com/mdevilliers/org-scrounger/pkg/util"
 	"github.com/shurcooL/githubv4"
 )
 
-func (c *client) GetUnreleasedCommitsForRepo(ctx context.Context, owner, reponame string) (UnreleasedCommits, RateLimit, error) { //nolint: lll, funlen
+const (
+	releaseSourceRelease = "release"
+	releaseSourceTag     = "tag"
+
+	compareStatusDiverged = "DIVERGED"
+)
+
+// ErrReleaseNotCompared is returned when github can't compare the last release with the branch
+// e.g. the tag of the release has been deleted
+var ErrReleaseNotCompared = errors.New("release can't be compared with the branch")
+
+// tagCommit is the commit a tag points to
+type tagCommit struct {
+	Oid           githubv4.GitObjectID `json:"oid"`
+	CommittedDate githubv4.DateTime    `json:"committed_date"`
+}
+
+// GetUnreleasedCommitsForRepo returns the commits on the branch, or the repository's
+// default branch if branch is empty, since the last release. The latest non-prerelease
+// Github Release is preferred falling back to the highest semver tag.
+func (c *Client) GetUnreleasedCommitsForRepo(ctx context.Context, owner, reponame, branch string) (UnreleasedCommits, RateLimit, error) { //nolint: lll, funlen
 	ret := UnreleasedCommits{}
 
-	// get last tag - should be a release really but things are a bit weird in this org
-	// work through the the commits looking for the oid of the last tag
+	type headRef struct {
+		Name githubv4.String `json:"name"`
+	}
+
 	var query struct {
 		RateLimit  RateLimit `json:"rate_limit"`
 		Repository struct {
-			Refs struct {
-				Nodes []struct {
-					Name   githubv4.String `json:"name"`
-					Target struct {
-						Oid       githubv4.String `json:"oid"`
-						CommitURL githubv4.String `json:"commit_url"`
-					} `json:"target"`
-				} `json:"nodes"`
-			} `graphql:"refs(last:1, refPrefix: \"refs/tags/\", orderBy: {field: TAG_COMMIT_DATE, direction: ASC} )" json:"refs"`
-			Ref struct {
-				Target struct {
-					Commit struct {
-						History struct {
-							Nodes []struct {
-								AbbreviatedOid githubv4.String `json:"abbreviated_oid"`
-								Oid            githubv4.String `json:"oid"`
-								Message        githubv4.String `json:"message"`
-								URL            githubv4.String `json:"url"`
-							} `json:"nodes"`
-						} `json:"history"`
-					} `graphql:"... on Commit" json:"commit"`
-				} `json:"target"`
-			} `graphql:"ref(qualifiedName: \"main\")" json:"ref"`
+			LatestRelease *struct {
+				TagName     githubv4.String   `json:"tag_name"`
+				Name        githubv4.String   `json:"name"`
+				PublishedAt githubv4.DateTime `json:"published_at"`
+				TagCommit   struct {
+					Oid githubv4.GitObjectID `json:"oid"`
+				} `json:"tag_commit"`
+			} `json:"latest_release"`
+			Ref       headRef `graphql:"ref: defaultBranchRef @skip(if: $hasBranch)" json:"ref"`
+			BranchRef headRef `graphql:"branchRef: ref(qualifiedName: $branch) @include(if: $hasBranch)" json:"branch_ref"`
 		} `graphql:"repository(owner:$owner, name:$name)" json:"repository"`
 	}
 	variables := map[string]interface{}{
-		"owner": githubv4.String(owner),
-		"name":  githubv4.String(reponame),
+		"owner":     githubv4.String(owner),
+		"name":      githubv4.String(reponame),
+		"branch":    githubv4.String(branch),
+		"hasBranch": githubv4.Boolean(branch != ""),
 	}
 
-	if err := c.graph.Query(ctx, &query, variables); err != nil {
+	if err := c.query(ctx, "GetUnreleasedCommitsForRepo", &query, variables); err != nil {
 		return ret, query.RateLimit, fmt.Errorf("error querying github: %w", err)
 	}
-	latestTagOid := "unknown"
-	ret.LastTag = Tag{Oid: latestTagOid, Tag: "unknown"}
-
-	if len(query.Repository.Refs.Nodes) == 1 {
-		latestTagOid = string(query.Repository.Refs.Nodes[0].Target.Oid)
-		commitURL := string(query.Repository.Refs.Nodes[0].Target.CommitURL)
-		// if a tagged commit has two parents, trusting the URL commitUrl
-		// as the Oid is better. Git is complicated...
-		if !strings.Contains(commitURL, latestTagOid) {
-			bits := strings.Split(commitURL, "/")
-			latestTagOid = bits[len(bits)-1]
+	rl := query.RateLimit
+	head := query.Repository.Ref
+	if branch != "" {
+		head = query.Repository.BranchRef
+	}
+	ret.Branch = string(head.Name)
+	ret.LastTag = Tag{Oid: "unknown", Tag: "unknown"}
+
+	if r := query.Repository.LatestRelease; r != nil {
+		ret.LastTag = Tag{Oid: string(r.TagCommit.Oid), Tag: string(r.TagName)}
+		ret.LastRelease = &Release{
+			Tag:         string(r.TagName),
+			Name:        string(r.Name),
+			PublishedAt: r.PublishedAt.Time,
+			Source:      releaseSourceRelease,
+		}
+	} else {
+		commits, r, err := c.getTagCommits(ctx, owner, reponame)
+		rl = rl.Add(r)
+		if err != nil {
+			return ret, rl, err
+		}
+		tags := []string{}
+		for t := range commits {
+			tags = append(tags, t)
+		}
+		if latest, found := util.LatestSemverTag(tags); found {
+			commit := commits[latest]
+			ret.LastTag = Tag{Oid: string(commit.Oid), Tag: latest}
+			ret.LastRelease = &Release{
+				Tag:         latest,
+				Name:        latest,
+				PublishedAt: commit.CommittedDate.Time,
+				Source:      releaseSourceTag,
+			}
+		}
+	}
+
+	if ret.Branch == "" {
+		ret.Summary = fmt.Sprintf("Branch '%s' not found for %s/%s.", branch, owner, reponame)
+		return ret, rl, nil
+	}
+
+	if ret.LastRelease == nil {
+		ret.Summary = fmt.Sprintf(`No releases or semver tags found for %s/%s.
+Are there any tags for the repo?`, owner, reponame)
+		return ret, rl, nil
+	}
+
+	rl, err := c.compare(ctx, owner, reponame, ret.LastRelease.Tag, ret.Branch, &ret, rl)
+	if err != nil {
+		return ret, rl, fmt.Errorf("error comparing %s to %s: %w", ret.LastRelease.Tag, ret.Branch, err)
+	}
+	return ret, rl, nil
+}
+
+// compare populates ret with the commits on head that aren't in the tag
+func (c *Client) compare(ctx context.Context, owner, reponame, tag, head string, ret *UnreleasedCommits, rl RateLimit) (RateLimit, error) { //nolint: lll, funlen
+
+	type comparison struct {
+		RateLimit  RateLimit `json:"rate_limit"`
+		Repository struct {
+			Ref *struct {
+				Compare *struct {
+					AheadBy  githubv4.Int    `json:"ahead_by"`
+					BehindBy githubv4.Int    `json:"behind_by"`
+					Status   githubv4.String `json:"status"`
+					Commits  struct {
+						Nodes []struct {
+							AbbreviatedOid githubv4.String   `json:"abbreviated_oid"`
+							Oid            githubv4.String   `json:"oid"`
+							Message        githubv4.String   `json:"message"`
+							URL            githubv4.String   `json:"url"`
+							CommittedDate  githubv4.DateTime `json:"committed_date"`
+							Author         struct {
+								Name githubv4.String `json:"name"`
+								User *struct {
+									Login githubv4.String `json:"login"`
+								} `json:"user"`
+							} `json:"author"`
+						} `json:"nodes"`
+						PageInfo PageInfo `json:"page_info"`
+					} `graphql:"commits(first:100, after:$cursor)" json:"commits"`
+				} `graphql:"compare(headRef:$head)" json:"compare"`
+			} `graphql:"ref(qualifiedName:$base)" json:"ref"`
+		} `graphql:"repository(owner:$owner, name:$name)" json:"repository"`
+	}
+
+	commits := []Commit{}
+	authors := util.NewSet[string]()
+
+	fetch := func(cursor *githubv4.String) (PageInfo, RateLimit, error) {
+		var query comparison
+		variables := map[string]interface{}{
+			"owner":  githubv4.String(owner),
+			"name":   githubv4.String(reponame),
+			"base":   githubv4.String("refs/tags/" + tag),
+			"head":   githubv4.String(head),
+			"cursor": cursor,
+		}
+		if err := c.query(ctx, "GetUnreleasedCommitsForRepo.Compare", &query, variables); err != nil {
+			return PageInfo{}, query.RateLimit, err
+		}
+		// github returns nothing rather than an error if the tag or branch isn't found
+		ref := query.Repository.Ref
+		if ref == nil || ref.Compare == nil {
+			return PageInfo{}, query.RateLimit, ErrReleaseNotCompared
+		}
+		compare := ref.Compare
+		ret.AheadBy = int(compare.AheadBy)
+		ret.BehindBy = int(compare.BehindBy)
+
+		if compare.Status == compareStatusDiverged {
+			ret.Summary = fmt.Sprintf(`%s has diverged from the last release %s.
+%d commits ahead and %d commits behind.`, head, tag, compare.AheadBy, compare.BehindBy)
 		}
-		ret.LastTag = Tag{Oid: latestTagOid, Tag: string(query.Repository.Refs.Nodes[0].Name)}
+
+		for _, commit := range compare.Commits.Nodes {
+			author := string(commit.Author.Name)
+			if commit.Author.User != nil {
+				author = string(commit.Author.User.Login)
+			}
+			authors.Add(author)
+			commits = append(commits, Commit{
+				Message:        string(commit.Message),
+				Oid:            string(commit.Oid),
+				AbbreviatedOid: string(commit.AbbreviatedOid),
+				URL:            string(commit.URL),
+				Author:         author,
+				CommittedDate:  commit.CommittedDate.Time,
+			})
+		}
+		return compare.Commits.PageInfo, query.RateLimit, nil
+	}
+
+	pageInfo, r, err := fetch(nil)
+	rl = rl.Add(r)
+	if err != nil {
+		return rl, err
+	}
+	rl, err = c.paginate(pageInfo, rl, func(cursor githubv4.String) (PageInfo, RateLimit, error) {
+		return fetch(&cursor)
+	})
+	if err != nil {
+		return rl, err
+	}
+
+	// github returns the oldest commit first, list the newest first
+	for i, j := 0, len(commits)-1; i < j; i, j = i+1, j-1 {
+		commits[i], commits[j] = commits[j], commits[i]
+	}
+	ret.Commits = commits
+	ret.Authors = authors.OrderedKeys()
+	return rl, nil
+}
+
+// getTagCommits returns the commit of each tag of the repository
+func (c *Client) getTagCommits(ctx context.Context, owner, reponame string) (map[string]tagCommit, RateLimit, error) {
+
+	type tags struct {
+		RateLimit  RateLimit `json:"rate_limit"`
+		Repository struct {
+			Refs struct {
+				Nodes []struct {
+					Name   githubv4.String `json:"name"`
+					Target struct {
+						Commit tagCommit `graphql:"... on Commit" json:"commit"`
+						Tag    struct {
+							Target struct {
+								Commit tagCommit `graphql:"... on Commit" json:"commit"`
+							} `json:"target"`
+						} `graphql:"... on Tag" json:"tag"`
+					} `json:"target"`
+				} `json:"nodes"`
+				PageInfo PageInfo `json:"page_info"`
+			} `graphql:"refs(first:100, after:$cursor, refPrefix: \"refs/tags/\", orderBy: {field: TAG_COMMIT_DATE, direction: DESC})" json:"refs"` //nolint: lll
+		} `graphql:"repository(owner:$owner, name:$name)" json:"repository"`
 	}
 
-	for _, commit := range query.Repository.Ref.Target.Commit.History.Nodes {
-		oid := string(commit.Oid)
-		if oid == latestTagOid {
-			break
+	ret := map[string]tagCommit{}
+
+	fetch := func(cursor *githubv4.String) (PageInfo, RateLimit, error) {
+		var query tags
+		variables := map[string]interface{}{
+			"owner":  githubv4.String(owner),
+			"name":   githubv4.String(reponame),
+			"cursor": cursor,
+		}
+		if err := c.query(ctx, "GetUnreleasedCommitsForRepo.Tags", &query, variables); err != nil {
+			return PageInfo{}, query.RateLimit, fmt.Errorf("error querying github: %w", err)
 		}
-		ret.Commits = append(ret.Commits, Commit{
-			Message:        string(commit.Message),
-			Oid:            string(commit.Oid),
-			AbbreviatedOid: string(commit.AbbreviatedOid),
-			URL:            string(commit.URL),
-		})
+		for _, t := range query.Repository.Refs.Nodes {
+			commit := t.Target.Commit
+			// annotated tags point to a tag object rather than a commit
+			if commit.Oid == "" {
+				commit = t.Target.Tag.Target.Commit
+			}
+			ret[string(t.Name)] = commit
+		}
+		return query.Repository.Refs.PageInfo, query.RateLimit, nil
 	}
 
-	if len(ret.Commits) == len(query.Repository.Ref.Target.Commit.History.Nodes) {
-		ret.Summary = fmt.Sprintf(`%d commits since the last tag.
-Are there any tags for the repo?
-Or mabe the last tagged commit isn't listed in the commits. Last tag: %s (%s)`,
-			len(ret.Commits), ret.LastTag.Tag, ret.LastTag.Oid)
+	pageInfo, rl, err := fetch(nil)
+	if err != nil {
+		return nil, rl, err
+	}
+	rl, err = c.paginate(pageInfo, rl, func(cursor githubv4.String) (PageInfo, RateLimit, error) {
+		return fetch(&cursor)
+	})
+	if err != nil {
+		return nil, rl, err
 	}
-	return ret, query.RateLimit, nil
+	return ret, rl, nil
 }
@@ -0,0 +1,96 @@
+package gh
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetUnreleasedCommitsFallsBackToTags(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+
+		switch {
+		case strings.Contains(body.Query, "refs("):
+			// the newest version is on the second page of tags
+			if body.Variables["cursor"] == nil {
+				_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1},"repository":{"refs":{"nodes":[
+					{"name":"v1.9.0","target":{"oid":"bbb","committedDate":"2024-01-01T00:00:00Z"}}],
+					"pageInfo":{"hasNextPage":true,"endCursor":"next"}}}}}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1},"repository":{"refs":{"nodes":[
+				{"name":"v1.10.0","target":{"oid":"aaa","committedDate":"2024-01-02T00:00:00Z"}}],
+				"pageInfo":{"hasNextPage":false}}}}}`))
+			return
+		case !strings.Contains(body.Query, "compare("):
+			_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1},"repository":{"latestRelease":null,"ref":{"name":"trunk"}}}}`))
+			return
+		}
+		require.Equal(t, "refs/tags/v1.10.0", body.Variables["base"])
+		require.Equal(t, "trunk", body.Variables["head"])
+		_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1},"repository":{"ref":{"compare":{
+			"aheadBy":2,"behindBy":0,"status":"AHEAD","commits":{"nodes":[
+				{"oid":"c1","message":"one","author":{"name":"Someone","user":{"login":"someone"}}},
+				{"oid":"c2","message":"two","author":{"name":"Other","user":null}}],
+			"pageInfo":{"hasNextPage":false}}}}}}}`))
+	}))
+	defer server.Close()
+
+	endpoints, err := ParseAPIURL(server.URL)
+	require.Nil(t, err)
+
+	client := NewClientFromGithubPAT(context.Background(), "token", WithEndpoints(endpoints))
+	unreleased, rl, err := client.GetUnreleasedCommitsForRepo(context.Background(), "org", "foo", "")
+	require.Nil(t, err)
+	require.Equal(t, 4, int(rl.Cost))
+
+	require.Equal(t, "trunk", unreleased.Branch)
+	require.Equal(t, "v1.10.0", unreleased.LastRelease.Tag)
+	require.Equal(t, "tag", unreleased.LastRelease.Source)
+	require.Equal(t, 2024, unreleased.LastRelease.PublishedAt.Year())
+	require.Equal(t, "aaa", unreleased.LastTag.Oid)
+	require.Equal(t, 2, unreleased.AheadBy)
+	require.Equal(t, []string{"Other", "someone"}, unreleased.Authors)
+	require.Len(t, unreleased.Commits, 2)
+	require.Equal(t, "c2", unreleased.Commits[0].Oid)
+	require.Empty(t, unreleased.Summary)
+}
+
+func Test_GetUnreleasedCommitsWithoutTheReleaseTag(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query string `json:"query"`
+		}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+
+		if !strings.Contains(body.Query, "compare(") {
+			_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1},"repository":{"latestRelease":{
+				"tagName":"v1.0.0","name":"v1.0.0","publishedAt":"2024-01-01T00:00:00Z","tagCommit":{"oid":"aaa"}},
+				"ref":{"name":"trunk"}}}}`))
+			return
+		}
+		// the tag of the release has been deleted
+		_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1},"repository":{"ref":null}}}`))
+	}))
+	defer server.Close()
+
+	endpoints, err := ParseAPIURL(server.URL)
+	require.Nil(t, err)
+
+	client := NewClientFromGithubPAT(context.Background(), "token", WithEndpoints(endpoints))
+	_, _, err = client.GetUnreleasedCommitsForRepo(context.Background(), "org", "foo", "")
+	require.True(t, errors.Is(err, ErrReleaseNotCompared))
+}
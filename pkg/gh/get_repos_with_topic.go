@@ -7,7 +7,7 @@ import (
 	"github.com/shurcooL/githubv4"
 )
 
-func (c *client) GetReposWithTopic(ctx context.Context, owner, topic string) ([]RepositorySlim, RateLimit, error) { //nolint: lll, funlen
+func (c *Client) GetReposWithTopic(ctx context.Context, owner, topic string) ([]RepositorySlim, RateLimit, error) { //nolint: lll, funlen
 
 	var query struct {
 		RateLimit RateLimit `json:"rate_limit"`
@@ -50,7 +50,7 @@ func (c *client) GetReposWithTopic(ctx context.Context, owner, topic string) ([]
 	rl := RateLimit{}
 
 	for {
-		if err := c.graph.Query(ctx, &query, variables); err != nil {
+		if err := c.query(ctx, "GetReposWithTopic", &query, variables); err != nil {
 			return nil, rl, fmt.Errorf("error querying github: %w", err)
 		}
 		for _, r := range query.Search.Nodes {
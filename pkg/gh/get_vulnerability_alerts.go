@@ -0,0 +1,43 @@
+package gh
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// GetVulnerabilityAlerts returns all of the open vulnerability alerts for a repository
+func (c *Client) GetVulnerabilityAlerts(ctx context.Context, owner, reponame string) (VulnerabilityAlerts, RateLimit, error) { //nolint: lll
+	ret := VulnerabilityAlerts{}
+
+	fetch := func(cursor *githubv4.String) (PageInfo, RateLimit, error) {
+		var query struct {
+			RateLimit  RateLimit `json:"rate_limit"`
+			Repository struct {
+				VulnerabilityAlerts VulnerabilityAlerts `graphql:"vulnerabilityAlerts(first:100, after:$cursor, states:[OPEN])" json:"vulnerability_alerts"`
+			} `graphql:"repository(owner:$owner, name:$name)" json:"repository"`
+		}
+		variables := map[string]interface{}{
+			"owner":  githubv4.String(owner),
+			"name":   githubv4.String(reponame),
+			"cursor": cursor,
+		}
+		if err := c.query(ctx, "GetVulnerabilityAlerts", &query, variables); err != nil {
+			return PageInfo{}, query.RateLimit, err
+		}
+		ret.Edges = append(ret.Edges, query.Repository.VulnerabilityAlerts.Edges...)
+		return query.Repository.VulnerabilityAlerts.PageInfo, query.RateLimit, nil
+	}
+
+	pageInfo, rl, err := fetch(nil)
+	if err == nil {
+		rl, err = c.paginate(pageInfo, rl, func(cursor githubv4.String) (PageInfo, RateLimit, error) {
+			return fetch(&cursor)
+		})
+	}
+	if err != nil {
+		return ret, rl, fmt.Errorf("error querying vulnerability alerts of %s/%s: %w", owner, reponame, err)
+	}
+	return ret, rl, nil
+}
@@ -0,0 +1,170 @@
+package gh
+
+import (
+	"context"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// WithMaxPages limits the number of pages fetched for each of the
+// connections of a repository's details, trading completeness for speed.
+// A value of 0 fetches every page.
+func WithMaxPages(n int) Option {
+	return func(c *Client) {
+		c.maxPages = n
+	}
+}
+
+// paginate calls fetch with the cursor of the next page until there are no more pages
+// or the maximum number of pages has been fetched, aggregating the RateLimit into rl
+func (c *Client) paginate(pageInfo PageInfo, rl RateLimit, fetch func(cursor githubv4.String) (PageInfo, RateLimit, error)) (RateLimit, error) {
+	for page := 1; pageInfo.HasNextPage; page++ {
+		if c.maxPages > 0 && page >= c.maxPages {
+			break
+		}
+		next, r, err := fetch(pageInfo.EndCursor)
+		rl = rl.Add(r)
+		if err != nil {
+			return rl, err
+		}
+		pageInfo = next
+	}
+	return rl, nil
+}
+
+// paginateRepository fetches the remaining pages of each of the repository's connections
+func (c *Client) paginateRepository(ctx context.Context, owner, reponame string, repo *Repository, rl RateLimit) (RateLimit, error) { //nolint: funlen, gocyclo
+	variables := func(cursor githubv4.String) map[string]interface{} {
+		return map[string]interface{}{
+			"owner":  githubv4.String(owner),
+			"name":   githubv4.String(reponame),
+			"cursor": cursor,
+		}
+	}
+
+	rl, err := c.paginate(repo.Languages.PageInfo, rl, func(cursor githubv4.String) (PageInfo, RateLimit, error) {
+		var query struct {
+			RateLimit  RateLimit `json:"rate_limit"`
+			Repository struct {
+				Languages LanguagesConnection `graphql:"languages(first:100, after:$cursor)" json:"languages"`
+			} `graphql:"repository(owner:$owner, name:$name)" json:"repository"`
+		}
+		if err := c.query(ctx, "GetRepoDetails.Languages", &query, variables(cursor)); err != nil {
+			return PageInfo{}, query.RateLimit, err
+		}
+		repo.Languages.Edges = append(repo.Languages.Edges, query.Repository.Languages.Edges...)
+		repo.Languages.Nodes = append(repo.Languages.Nodes, query.Repository.Languages.Nodes...)
+		return query.Repository.Languages.PageInfo, query.RateLimit, nil
+	})
+	if err != nil {
+		return rl, err
+	}
+
+	rl, err = c.paginate(repo.RepositoryTopics.PageInfo, rl, func(cursor githubv4.String) (PageInfo, RateLimit, error) {
+		var query struct {
+			RateLimit  RateLimit `json:"rate_limit"`
+			Repository struct {
+				RepositoryTopics RepositoryTopics `graphql:"repositoryTopics(first:100, after:$cursor)" json:"repository_topics"`
+			} `graphql:"repository(owner:$owner, name:$name)" json:"repository"`
+		}
+		if err := c.query(ctx, "GetRepoDetails.RepositoryTopics", &query, variables(cursor)); err != nil {
+			return PageInfo{}, query.RateLimit, err
+		}
+		repo.RepositoryTopics.Nodes = append(repo.RepositoryTopics.Nodes, query.Repository.RepositoryTopics.Nodes...)
+		return query.Repository.RepositoryTopics.PageInfo, query.RateLimit, nil
+	})
+	if err != nil {
+		return rl, err
+	}
+
+	rl, err = c.paginate(repo.PullRequests.PageInfo, rl, func(cursor githubv4.String) (PageInfo, RateLimit, error) {
+		var query struct {
+			RateLimit  RateLimit `json:"rate_limit"`
+			Repository struct {
+				PullRequests PullRequests `graphql:"pullRequests(first:30, after:$cursor, states:[OPEN], orderBy:{field:CREATED_AT, direction:DESC})" json:"pull_requests"` //nolint: lll
+			} `graphql:"repository(owner:$owner, name:$name)" json:"repository"`
+		}
+		if err := c.query(ctx, "GetRepoDetails.PullRequests", &query, variables(cursor)); err != nil {
+			return PageInfo{}, query.RateLimit, err
+		}
+		repo.PullRequests.Nodes = append(repo.PullRequests.Nodes, query.Repository.PullRequests.Nodes...)
+		return query.Repository.PullRequests.PageInfo, query.RateLimit, nil
+	})
+	if err != nil {
+		return rl, err
+	}
+
+	for i := range repo.PullRequests.Nodes {
+		rl, err = c.paginateComments(ctx, owner, reponame, &repo.PullRequests.Nodes[i], rl)
+		if err != nil {
+			return rl, err
+		}
+	}
+
+	rl, err = c.paginate(repo.VulnerabilityAlerts.PageInfo, rl, func(cursor githubv4.String) (PageInfo, RateLimit, error) {
+		var query struct {
+			RateLimit  RateLimit `json:"rate_limit"`
+			Repository struct {
+				VulnerabilityAlerts VulnerabilityAlerts `graphql:"vulnerabilityAlerts(first:100, after:$cursor, states:[OPEN])" json:"vulnerability_alerts"`
+			} `graphql:"repository(owner:$owner, name:$name)" json:"repository"`
+		}
+		if err := c.query(ctx, "GetRepoDetails.VulnerabilityAlerts", &query, variables(cursor)); err != nil {
+			return PageInfo{}, query.RateLimit, err
+		}
+		repo.VulnerabilityAlerts.Edges = append(repo.VulnerabilityAlerts.Edges, query.Repository.VulnerabilityAlerts.Edges...)
+		return query.Repository.VulnerabilityAlerts.PageInfo, query.RateLimit, nil
+	})
+	if err != nil {
+		return rl, err
+	}
+
+	commit := &repo.Ref.Target.Commit
+	return c.paginate(commit.StatusCheckRollup.Contexts.PageInfo, rl, func(cursor githubv4.String) (PageInfo, RateLimit, error) {
+		var query struct {
+			RateLimit  RateLimit `json:"rate_limit"`
+			Repository struct {
+				Object struct {
+					Commit struct {
+						StatusCheckRollup struct {
+							Contexts StatusCheckContexts `graphql:"contexts(first:100, after:$cursor)" json:"contexts"`
+						} `json:"statusCheckRollup"`
+					} `graphql:"... on Commit" json:"commit"`
+				} `graphql:"object(oid:$oid)" json:"object"`
+			} `graphql:"repository(owner:$owner, name:$name)" json:"repository"`
+		}
+		vars := variables(cursor)
+		vars["oid"] = commit.Oid
+		if err := c.query(ctx, "GetRepoDetails.Contexts", &query, vars); err != nil {
+			return PageInfo{}, query.RateLimit, err
+		}
+		contexts := query.Repository.Object.Commit.StatusCheckRollup.Contexts
+		commit.StatusCheckRollup.Contexts.Nodes = append(commit.StatusCheckRollup.Contexts.Nodes, contexts.Nodes...)
+		return contexts.PageInfo, query.RateLimit, nil
+	})
+}
+
+// paginateComments fetches the remaining pages of comments for a pull request
+func (c *Client) paginateComments(ctx context.Context, owner, reponame string, pr *PullRequest, rl RateLimit) (RateLimit, error) {
+	return c.paginate(pr.Comments.PageInfo, rl, func(cursor githubv4.String) (PageInfo, RateLimit, error) {
+		var query struct {
+			RateLimit  RateLimit `json:"rate_limit"`
+			Repository struct {
+				PullRequest struct {
+					Comments Comments `graphql:"comments(first:100, after:$cursor)" json:"comments"`
+				} `graphql:"pullRequest(number:$number)" json:"pull_request"`
+			} `graphql:"repository(owner:$owner, name:$name)" json:"repository"`
+		}
+		variables := map[string]interface{}{
+			"owner":  githubv4.String(owner),
+			"name":   githubv4.String(reponame),
+			"number": pr.Number,
+			"cursor": cursor,
+		}
+		if err := c.query(ctx, "GetRepoDetails.Comments", &query, variables); err != nil {
+			return PageInfo{}, query.RateLimit, err
+		}
+		comments := query.Repository.PullRequest.Comments
+		pr.Comments.Nodes = append(pr.Comments.Nodes, comments.Nodes...)
+		return comments.PageInfo, query.RateLimit, nil
+	})
+}
@@ -0,0 +1,62 @@
+package gh
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GetRepoDetailsPaginates(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+
+		switch {
+		case !strings.Contains(body.Query, "$cursor"):
+			_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1},"repository":{"name":"foo",
+				"pullRequests":{"nodes":[{"number":1,"title":"one","comments":{"nodes":[{"body":"a"}],"pageInfo":{"hasNextPage":true,"endCursor":"c1"}}}],
+				"pageInfo":{"hasNextPage":true,"endCursor":"p1"}}}}}`))
+		case strings.Contains(body.Query, "pullRequests(") && body.Variables["cursor"] == "p1":
+			_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1},"repository":{
+				"pullRequests":{"nodes":[{"number":2,"title":"two"}],"pageInfo":{"hasNextPage":true,"endCursor":"p2"}}}}}`))
+		case strings.Contains(body.Query, "pullRequests(") && body.Variables["cursor"] == "p2":
+			_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1},"repository":{
+				"pullRequests":{"nodes":[{"number":3,"title":"three"}],"pageInfo":{"hasNextPage":false}}}}}`))
+		case strings.Contains(body.Query, "pullRequest(number:$number)"):
+			require.Equal(t, float64(1), body.Variables["number"])
+			_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1},"repository":{"pullRequest":{
+				"comments":{"nodes":[{"body":"b"}],"pageInfo":{"hasNextPage":false}}}}}}`))
+		default:
+			t.Fatalf("unexpected query %s", body.Query)
+		}
+	}))
+	defer server.Close()
+
+	endpoints, err := ParseAPIURL(server.URL)
+	require.Nil(t, err)
+
+	client := NewClientFromGithubPAT(context.Background(), "token", WithEndpoints(endpoints))
+	repo, rl, err := client.GetRepoDetails(context.Background(), "org", "foo", "")
+	require.Nil(t, err)
+	require.Equal(t, 4, int(rl.Cost))
+
+	require.Len(t, repo.PullRequests.Nodes, 3)
+	require.Equal(t, "three", string(repo.PullRequests.Nodes[2].Title))
+	require.Len(t, repo.PullRequests.Nodes[0].Comments.Nodes, 2)
+	require.Equal(t, "b", string(repo.PullRequests.Nodes[0].Comments.Nodes[1].Body))
+
+	// cap the number of pages
+	client = NewClientFromGithubPAT(context.Background(), "token", WithEndpoints(endpoints), WithMaxPages(2))
+	repo, _, err = client.GetRepoDetails(context.Background(), "org", "foo", "")
+	require.Nil(t, err)
+	require.Len(t, repo.PullRequests.Nodes, 2)
+}
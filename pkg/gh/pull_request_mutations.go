@@ -0,0 +1,62 @@
+package gh
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/shurcooL/githubv4"
+)
+
+// ApprovePullRequest submits an approving review with the body
+func (c *Client) ApprovePullRequest(ctx context.Context, pr PullRequest, body string) error {
+	var mutation struct {
+		AddPullRequestReview struct {
+			PullRequestReview struct {
+				State githubv4.String
+			}
+		} `graphql:"addPullRequestReview(input: $input)"`
+	}
+
+	event := githubv4.PullRequestReviewEventApprove
+	input := githubv4.AddPullRequestReviewInput{
+		PullRequestID: pr.ID,
+		CommitOID:     &pr.HeadRefOid,
+		Event:         &event,
+		Body:          githubv4.NewString(githubv4.String(body)),
+	}
+
+	if err := c.mutate(ctx, &mutation, input); err != nil {
+		return fmt.Errorf("error approving pull request %s: %w", pr.URL, err)
+	}
+	return nil
+}
+
+// MergePullRequest merges the pull request using the method e.g. MERGE, SQUASH or REBASE.
+// The merge fails if the head of the pull request has moved since it was queried.
+func (c *Client) MergePullRequest(ctx context.Context, pr PullRequest, method string) error {
+	var mutation struct {
+		MergePullRequest struct {
+			PullRequest struct {
+				State githubv4.String
+			}
+		} `graphql:"mergePullRequest(input: $input)"`
+	}
+
+	mergeMethod := githubv4.PullRequestMergeMethod(method)
+	input := githubv4.MergePullRequestInput{
+		PullRequestID:   pr.ID,
+		ExpectedHeadOid: &pr.HeadRefOid,
+		MergeMethod:     &mergeMethod,
+	}
+
+	if err := c.mutate(ctx, &mutation, input); err != nil {
+		return fmt.Errorf("error merging pull request %s: %w", pr.URL, err)
+	}
+	return nil
+}
+
+// mutate executes the mutation without retrying, a mutation that failed with a
+// server error may have been applied so could be applied twice
+func (c *Client) mutate(ctx context.Context, m interface{}, input githubv4.Input) error {
+	return c.graph.Mutate(withoutRetries(ctx), m, input, nil)
+}
@@ -1,6 +1,14 @@
 package gh
 
-import "github.com/shurcooL/githubv4"
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/shurcooL/githubv4"
+)
 
 // RateLimit contains information from github as to our
 // existing rate limit quota for the token
@@ -19,3 +27,80 @@ func (rl RateLimit) Add(rl2 RateLimit) RateLimit {
 	rl.ResetAt = rl2.ResetAt
 	return rl
 }
+
+// budget tracks the rate limit across all of the queries made by a Client
+// pausing callers when the remaining quota drops below the threshold
+type budget struct {
+	mu        sync.Mutex
+	total     RateLimit
+	threshold int
+}
+
+// observe records the RateLimit returned by a query
+func (b *budget) observe(rl RateLimit) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// responses can arrive out of order so only
+	// trust the lowest remaining value for a reset window
+	total := b.total.Add(rl)
+	if b.total.ResetAt.Equal(rl.ResetAt.Time) && b.total.Remaining < rl.Remaining {
+		total.Remaining = b.total.Remaining
+	}
+	b.total = total
+}
+
+// current returns the aggregated RateLimit
+func (b *budget) current() RateLimit {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.total
+}
+
+// wait blocks until the quota has been reset if the remaining
+// quota is below the threshold
+func (b *budget) wait(ctx context.Context) error {
+	b.mu.Lock()
+	rl := b.total
+	b.mu.Unlock()
+
+	if b.threshold <= 0 || rl.Limit == 0 || int(rl.Remaining) >= b.threshold {
+		return nil
+	}
+	d := time.Until(rl.ResetAt.Time)
+	if d <= 0 {
+		return nil
+	}
+
+	log.Warn().Interface("rate-limit", rl).Msgf("github rate limit below %d, pausing for %s", b.threshold, d)
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// assume the quota has been replenished, the next response will correct us
+	if b.total.ResetAt.Equal(rl.ResetAt.Time) {
+		b.total.Remaining = b.total.Limit
+	}
+	return nil
+}
+
+// rateLimitOf returns the RateLimit field of a query if it has one
+func rateLimitOf(q interface{}) (RateLimit, bool) {
+	v := reflect.Indirect(reflect.ValueOf(q))
+	if v.Kind() != reflect.Struct {
+		return RateLimit{}, false
+	}
+	f := v.FieldByName("RateLimit")
+	if !f.IsValid() {
+		return RateLimit{}, false
+	}
+	rl, ok := f.Interface().(RateLimit)
+	return rl, ok
+}
@@ -0,0 +1,261 @@
+package gh
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mdevilliers/org-scrounger/pkg/util"
+	"github.com/rs/zerolog/log"
+	"github.com/shurcooL/githubv4"
+)
+
+const (
+	// maxSearchResults is the most results github returns for a search
+	maxSearchResults = 1000
+	// botSuffix is appended to the logins of bots on github.com but isn't returned by the GraphQL API
+	botSuffix = "[bot]"
+	// maxSearchQueryLength is the longest search query github accepts
+	maxSearchQueryLength = 256
+
+	ownerTypeUser = "User"
+)
+
+// PullRequestFilter predicates on the properties of a pull request.
+// Empty fields match all pull requests.
+type PullRequestFilter struct {
+	// Repos restricts the pull requests to these repositories
+	Repos []string
+	// Authors are logins, the logins of github apps have the suffix '[bot]' e.g. 'dependabot[bot]'.
+	// Logins without the suffix are users including machine users.
+	Authors []string
+	// Labels matches pull requests with any of the labels
+	Labels []string
+	// OlderThan and NewerThan predicate on the age of the pull request
+	OlderThan time.Duration
+	NewerThan time.Duration
+	// ReviewStates are APPROVED, CHANGES_REQUESTED or REVIEW_REQUIRED
+	ReviewStates []string
+	Draft        *bool
+	// CIStates are the states of the last commit e.g. SUCCESS, FAILURE or PENDING
+	CIStates []string
+}
+
+// Matches returns true if the pull request satisfies the filter
+func (f PullRequestFilter) Matches(p PullRequest, now time.Time) bool { //nolint: gocyclo
+	if len(f.Repos) > 0 && !util.Contains(f.Repos, string(p.Repository.Name)) {
+		return false
+	}
+	if len(f.Authors) > 0 && !f.matchesAuthor(string(p.Author.Login)) {
+		return false
+	}
+	if len(f.Labels) > 0 && !containsAny(f.Labels, p.LabelNames()) {
+		return false
+	}
+	age := now.Sub(p.CreatedAt.Time)
+	if f.OlderThan > 0 && age < f.OlderThan {
+		return false
+	}
+	if f.NewerThan > 0 && age > f.NewerThan {
+		return false
+	}
+	if len(f.ReviewStates) > 0 && !containsFold(f.ReviewStates, string(p.ReviewDecision)) {
+		return false
+	}
+	if f.Draft != nil && *f.Draft != bool(p.IsDraft) {
+		return false
+	}
+	if len(f.CIStates) > 0 && !containsFold(f.CIStates, p.LastCommitState()) {
+		return false
+	}
+	return true
+}
+
+// matchesAuthor returns true if the login is one of the authors. The
+// GraphQL API returns the logins of bots without the '[bot]' suffix.
+func (f PullRequestFilter) matchesAuthor(login string) bool {
+	for _, a := range f.Authors {
+		if strings.TrimSuffix(a, botSuffix) == strings.TrimSuffix(login, botSuffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// searchQueries returns the queries searching for the open pull requests of the owner
+// narrowed by the filter, as github returns at most 1000 results for a search. A query
+// is returned for each author as they can't be ORed. Repos are searched in batches of
+// ORed repo qualifiers to keep within the length of a query. Pull requests still need to
+// be matched with the filter as not all of it can be expressed as search qualifiers.
+func (f PullRequestFilter) searchQueries(owner, ownerType string, omitArchived bool, now time.Time) []string {
+
+	qualifiers := []string{}
+	if omitArchived {
+		qualifiers = append(qualifiers, "archived:false")
+	}
+	if len(f.Labels) > 0 {
+		labels := []string{}
+		for _, l := range f.Labels {
+			labels = append(labels, fmt.Sprintf("%q", l))
+		}
+		// comma separated labels are ORed
+		qualifiers = append(qualifiers, "label:"+strings.Join(labels, ","))
+	}
+	if f.OlderThan > 0 {
+		qualifiers = append(qualifiers, "created:<"+now.Add(-f.OlderThan).UTC().Format(time.RFC3339))
+	}
+	if f.NewerThan > 0 {
+		qualifiers = append(qualifiers, "created:>"+now.Add(-f.NewerThan).UTC().Format(time.RFC3339))
+	}
+	if f.Draft != nil {
+		qualifiers = append(qualifiers, fmt.Sprintf("draft:%t", *f.Draft))
+	}
+	query := ""
+	if len(qualifiers) > 0 {
+		query = " " + strings.Join(qualifiers, " ")
+	}
+
+	authors := []string{""}
+	if len(f.Authors) > 0 {
+		authors = []string{}
+	}
+	for _, a := range f.Authors {
+		author := a
+		if strings.HasSuffix(a, botSuffix) {
+			// github apps are searched for by their app, machine users by their login
+			author = "app/" + strings.TrimSuffix(a, botSuffix)
+		}
+		authors = append(authors, " author:"+author)
+	}
+
+	const prefix = "is:pr is:open "
+
+	queries := []string{}
+	for _, author := range authors {
+		for _, scope := range f.searchScopes(owner, ownerType, len(prefix)+len(query)+len(author)) {
+			queries = append(queries, prefix+scope+query+author)
+		}
+	}
+	return queries
+}
+
+// searchScopes returns the qualifiers for the owner, or batches of ORed repo
+// qualifiers no longer than the space left in a query if the filter has repos
+func (f PullRequestFilter) searchScopes(owner, ownerType string, used int) []string {
+	if len(f.Repos) == 0 {
+		if ownerType == ownerTypeUser {
+			return []string{"user:" + owner}
+		}
+		return []string{"org:" + owner}
+	}
+
+	scopes := []string{}
+	batch := []string{}
+	length := used
+	for _, r := range f.Repos {
+		qualifier := fmt.Sprintf("repo:%s/%s", owner, r)
+		if len(batch) > 0 && length+len(qualifier)+1 > maxSearchQueryLength {
+			scopes = append(scopes, strings.Join(batch, " "))
+			batch, length = []string{}, used
+		}
+		batch = append(batch, qualifier)
+		length += len(qualifier) + 1
+	}
+	return append(scopes, strings.Join(batch, " "))
+}
+
+func containsAny(needles, haystack []string) bool {
+	for _, n := range needles {
+		if util.Contains(haystack, n) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(elems []string, v string) bool {
+	for _, e := range elems {
+		if strings.EqualFold(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchPullRequests returns the open pull requests for an owner narrowed by the filter.
+// Archived repositories are omitted if omitArchived is true. The pull requests returned
+// should still be matched with the filter.
+func (c *Client) SearchPullRequests(ctx context.Context, owner string, omitArchived bool, filter PullRequestFilter) ([]PullRequest, RateLimit, error) { //nolint: lll
+
+	var query struct {
+		RateLimit RateLimit `json:"rate_limit"`
+		Search    struct {
+			IssueCount githubv4.Int `json:"issue_count"`
+			PageInfo   PageInfo     `json:"page_info"`
+			Nodes      []struct {
+				PullRequest PullRequest `graphql:"... on PullRequest" json:"pull_request"`
+			} `json:"nodes"`
+		} `graphql:"search(query:$query, type: ISSUE, first: 50, after: $cursor)" json:"search"`
+	}
+
+	ownerType, rl, err := c.ownerType(ctx, owner)
+	if err != nil {
+		return nil, rl, err
+	}
+
+	ret := []PullRequest{}
+	seen := map[interface{}]bool{}
+
+	for _, queryStr := range filter.searchQueries(owner, ownerType, omitArchived, time.Now()) {
+
+		variables := map[string]interface{}{
+			"query":  githubv4.String(queryStr),
+			"cursor": (*githubv4.String)(nil),
+		}
+
+		for {
+			if err := c.query(ctx, "SearchPullRequests", &query, variables); err != nil {
+				return nil, rl, fmt.Errorf("error searching pull requests: %w", err)
+			}
+			for _, n := range query.Search.Nodes {
+				if seen[n.PullRequest.ID] {
+					continue
+				}
+				seen[n.PullRequest.ID] = true
+				ret = append(ret, n.PullRequest)
+			}
+			rl = rl.Add(query.RateLimit)
+
+			if !query.Search.PageInfo.HasNextPage {
+				break
+			}
+			variables["cursor"] = githubv4.NewString(query.Search.PageInfo.EndCursor)
+		}
+		if int(query.Search.IssueCount) > maxSearchResults {
+			log.Warn().Str("query", queryStr).Msgf("search matched %d pull requests, only the first %d are returned", query.Search.IssueCount, maxSearchResults)
+		}
+	}
+	return ret, rl, nil
+}
+
+// ownerType returns whether the owner is an Organization or a User
+func (c *Client) ownerType(ctx context.Context, owner string) (string, RateLimit, error) {
+
+	var query struct {
+		RateLimit       RateLimit `json:"rate_limit"`
+		RepositoryOwner *struct {
+			Typename githubv4.String `graphql:"__typename" json:"typename"`
+		} `graphql:"repositoryOwner(login:$owner)" json:"repository_owner"`
+	}
+	variables := map[string]interface{}{
+		"owner": githubv4.String(owner),
+	}
+	if err := c.query(ctx, "SearchPullRequests.OwnerType", &query, variables); err != nil {
+		return "", query.RateLimit, fmt.Errorf("error querying github: %w", err)
+	}
+	if query.RepositoryOwner == nil {
+		return "", query.RateLimit, fmt.Errorf("error searching pull requests: owner %s not found", owner)
+	}
+	return string(query.RepositoryOwner.Typename), query.RateLimit, nil
+}
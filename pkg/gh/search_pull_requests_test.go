@@ -0,0 +1,173 @@
+package gh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_PullRequestFilter(t *testing.T) {
+
+	now := time.Now()
+
+	pr := PullRequest{
+		CreatedAt:      githubv4.DateTime{Time: now.Add(-48 * time.Hour)},
+		IsDraft:        false,
+		ReviewDecision: "APPROVED",
+	}
+	pr.Repository.Name = "foo"
+	pr.Author.Login = "dependabot"
+	pr.Labels.Nodes = append(pr.Labels.Nodes, struct {
+		Name githubv4.String `json:"name"`
+	}{Name: "dependencies"})
+	pr.Commits.Nodes = make([]struct {
+		Commit struct {
+			StatusCheckRollup struct {
+				State githubv4.String `json:"state"`
+			} `json:"statusCheckRollup"`
+		} `json:"commit"`
+	}, 1)
+	pr.Commits.Nodes[0].Commit.StatusCheckRollup.State = "SUCCESS"
+
+	draft := true
+	notDraft := false
+
+	tests := []struct {
+		name     string
+		filter   PullRequestFilter
+		expected bool
+	}{
+		{name: "empty", filter: PullRequestFilter{}, expected: true},
+		{name: "repo", filter: PullRequestFilter{Repos: []string{"bar"}}, expected: false},
+		{name: "author", filter: PullRequestFilter{Authors: []string{"dependabot", "renovate"}}, expected: true},
+		{name: "bot author", filter: PullRequestFilter{Authors: []string{"dependabot[bot]"}}, expected: true},
+		{name: "other author", filter: PullRequestFilter{Authors: []string{"renovate[bot]"}}, expected: false},
+		{name: "label", filter: PullRequestFilter{Labels: []string{"security", "dependencies"}}, expected: true},
+		{name: "missing label", filter: PullRequestFilter{Labels: []string{"security"}}, expected: false},
+		{name: "older than", filter: PullRequestFilter{OlderThan: 24 * time.Hour}, expected: true},
+		{name: "too new", filter: PullRequestFilter{OlderThan: 72 * time.Hour}, expected: false},
+		{name: "newer than", filter: PullRequestFilter{NewerThan: 24 * time.Hour}, expected: false},
+		{name: "review state", filter: PullRequestFilter{ReviewStates: []string{"approved"}}, expected: true},
+		{name: "draft", filter: PullRequestFilter{Draft: &draft}, expected: false},
+		{name: "not draft", filter: PullRequestFilter{Draft: &notDraft}, expected: true},
+		{name: "ci state", filter: PullRequestFilter{CIStates: []string{"FAILURE"}}, expected: false},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.expected, test.filter.Matches(pr, now), test.name)
+	}
+}
+
+func Test_PullRequestSearchQueries(t *testing.T) {
+
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	draft := false
+
+	testCases := []struct {
+		desc         string
+		filter       PullRequestFilter
+		ownerType    string
+		omitArchived bool
+		expected     []string
+	}{
+		{desc: "empty", expected: []string{"is:pr is:open org:foo"}},
+		{desc: "user", ownerType: "User", expected: []string{"is:pr is:open user:foo"}},
+		{
+			desc:         "repos",
+			filter:       PullRequestFilter{Repos: []string{"one", "two"}, Authors: []string{"someone"}},
+			omitArchived: true,
+			expected:     []string{"is:pr is:open repo:foo/one repo:foo/two archived:false author:someone"},
+		},
+		{desc: "omit archived", omitArchived: true, expected: []string{"is:pr is:open org:foo archived:false"}},
+		{
+			desc:   "authors",
+			filter: PullRequestFilter{Authors: []string{"someone", "dependabot[bot]"}},
+			expected: []string{
+				"is:pr is:open org:foo author:someone",
+				"is:pr is:open org:foo author:app/dependabot",
+			},
+		},
+		{
+			desc:     "labels",
+			filter:   PullRequestFilter{Labels: []string{"security", "good first issue"}},
+			expected: []string{`is:pr is:open org:foo label:"security","good first issue"`},
+		},
+		{
+			desc:     "age and draft",
+			filter:   PullRequestFilter{OlderThan: 24 * time.Hour, NewerThan: 72 * time.Hour, Draft: &draft},
+			expected: []string{"is:pr is:open org:foo created:<2024-01-09T12:00:00Z created:>2024-01-07T12:00:00Z draft:false"},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			require.Equal(t, tC.expected, tC.filter.searchQueries("foo", tC.ownerType, tC.omitArchived, now))
+		})
+	}
+}
+
+func Test_PullRequestSearchQueriesBatchRepos(t *testing.T) {
+
+	filter := PullRequestFilter{Authors: []string{"dependabot[bot]"}}
+	for i := 0; i < 20; i++ {
+		filter.Repos = append(filter.Repos, fmt.Sprintf("a-repository-with-a-long-name-%02d", i))
+	}
+
+	queries := filter.searchQueries("foo", "Organization", true, time.Now())
+	require.Greater(t, len(queries), 1)
+
+	repos := []string{}
+	for _, q := range queries {
+		require.LessOrEqual(t, len(q), maxSearchQueryLength)
+		require.True(t, strings.HasSuffix(q, " archived:false author:app/dependabot"), q)
+		for _, field := range strings.Fields(q) {
+			if strings.HasPrefix(field, "repo:foo/") {
+				repos = append(repos, strings.TrimPrefix(field, "repo:foo/"))
+			}
+		}
+	}
+	require.Equal(t, filter.Repos, repos)
+}
+
+func Test_SearchPullRequestsWithFilter(t *testing.T) {
+
+	queries := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query     string                 `json:"query"`
+			Variables map[string]interface{} `json:"variables"`
+		}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+		if strings.Contains(body.Query, "repositoryOwner(") {
+			_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1},"repositoryOwner":{"__typename":"User"}}}`))
+			return
+		}
+		queries = append(queries, body.Variables["query"].(string))
+
+		_, _ = w.Write([]byte(`{"data":{"rateLimit":{"cost":1},"search":{"issueCount":1,
+			"pageInfo":{"hasNextPage":false},"nodes":[{"id":"PR_1","number":1}]}}}`))
+	}))
+	defer server.Close()
+
+	endpoints, err := ParseAPIURL(server.URL)
+	require.Nil(t, err)
+
+	client := NewClientFromGithubPAT(context.Background(), "token", WithEndpoints(endpoints))
+	filter := PullRequestFilter{Authors: []string{"dependabot[bot]", "renovate[bot]"}}
+	prs, rl, err := client.SearchPullRequests(context.Background(), "org", true, filter)
+	require.Nil(t, err)
+	require.Equal(t, 3, int(rl.Cost))
+	require.Equal(t, []string{
+		"is:pr is:open user:org archived:false author:app/dependabot",
+		"is:pr is:open user:org archived:false author:app/renovate",
+	}, queries)
+	// the same pull request is only returned once
+	require.Len(t, prs, 1)
+}
@@ -0,0 +1,114 @@
+package gh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const maxBackoff = time.Minute
+
+// noRetryKey marks the context of requests that mustn't be retried
+type noRetryKey struct{}
+
+// withoutRetries returns a context whose requests aren't retried e.g. mutations which
+// may have been applied even though github responded with an error
+func withoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetryKey{}, true)
+}
+
+// retryTransport retries requests that failed due to githubs secondary
+// rate limits or server errors and pauses requests when the rate limit
+// budget is exhausted
+type retryTransport struct {
+	next       http.RoundTripper
+	budget     *budget
+	maxRetries int
+	backoff    time.Duration
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading request body: %w", err)
+		}
+		body = b
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := t.budget.wait(ctx); err != nil {
+			return nil, err
+		}
+
+		r := req.Clone(ctx)
+		if body != nil {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		resp, err := t.next.RoundTrip(r)
+		if err != nil || attempt >= t.maxRetries || ctx.Value(noRetryKey{}) != nil {
+			return resp, err
+		}
+
+		wait, retry, err := t.shouldRetry(resp, attempt)
+		if err != nil || !retry {
+			return resp, err
+		}
+
+		log.Warn().Int("status", resp.StatusCode).Int("attempt", attempt+1).Msgf("retrying github request in %s", wait)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// shouldRetry returns how long to wait and true if the response should be retried.
+// Retried responses have their body closed; other responses are left readable.
+func (t *retryTransport) shouldRetry(resp *http.Response, attempt int) (time.Duration, bool, error) {
+	wait := t.backoff << attempt
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError:
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		b, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return 0, false, fmt.Errorf("error reading response body: %w", err)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(b))
+
+		retryAfter := resp.Header.Get("Retry-After")
+		if retryAfter == "" && !strings.Contains(strings.ToLower(string(b)), "secondary rate limit") {
+			return 0, false, nil
+		}
+		if seconds, err := strconv.Atoi(retryAfter); err == nil {
+			wait = time.Duration(seconds) * time.Second
+		}
+	default:
+		return 0, false, nil
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return wait, true, nil
+}
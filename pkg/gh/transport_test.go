@@ -0,0 +1,109 @@
+package gh
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RetryTransport(t *testing.T) {
+
+	responses := []func(w http.ResponseWriter){
+		func(w http.ResponseWriter) { w.WriteHeader(http.StatusBadGateway) },
+		func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = w.Write([]byte(`{"message":"You have exceeded a secondary rate limit."}`))
+		},
+		func(w http.ResponseWriter) { _, _ = w.Write([]byte(`ok`)) },
+	}
+	bodies := []string{}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(b))
+		responses[len(bodies)-1](w)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &retryTransport{
+		next:       http.DefaultTransport,
+		budget:     &budget{},
+		maxRetries: 3,
+		backoff:    time.Millisecond,
+	}}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodPost, server.URL, strings.NewReader("query"))
+	require.Nil(t, err)
+
+	resp, err := client.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Equal(t, "ok", string(b))
+	require.Equal(t, []string{"query", "query", "query"}, bodies)
+}
+
+func Test_RetryTransportDoesNotRetryForbidden(t *testing.T) {
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`forbidden`))
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &retryTransport{
+		next:       http.DefaultTransport,
+		budget:     &budget{},
+		maxRetries: 3,
+		backoff:    time.Millisecond,
+	}}
+
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, server.URL, http.NoBody)
+	require.Nil(t, err)
+
+	resp, err := client.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	require.Nil(t, err)
+	require.Equal(t, "forbidden", string(b))
+	require.Equal(t, 1, calls)
+}
+
+func Test_RetryTransportDoesNotRetryMutations(t *testing.T) {
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &retryTransport{
+		next:       http.DefaultTransport,
+		budget:     &budget{},
+		maxRetries: 3,
+		backoff:    time.Millisecond,
+	}}
+
+	req, err := http.NewRequestWithContext(withoutRetries(context.Background()), http.MethodPost, server.URL, strings.NewReader("mutation"))
+	require.Nil(t, err)
+
+	resp, err := client.Do(req)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+
+	require.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	require.Equal(t, 1, calls)
+}
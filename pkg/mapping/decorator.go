@@ -16,6 +16,16 @@ const (
 	sonarcloudNamespace = "sonarcloud"
 )
 
+// container types of a workload
+const (
+	ContainerTypeMain      = "main"
+	ContainerTypeInit      = "init"
+	ContainerTypeSidecar   = "sidecar"
+	ContainerTypeEphemeral = "ephemeral"
+	// ContainerTypeCustom is an image found at a custom path e.g. in a CRD
+	ContainerTypeCustom = "custom"
+)
+
 //counterfeiter:generate . repoGetter
 type repoGetter interface {
 	GetRepoByName(ctx context.Context, owner, reponame string) (gh.RepositorySlim, gh.RateLimit, error)
@@ -35,6 +45,29 @@ type (
 		Repo                      *gh.RepositorySlim `json:"repo,omitempty"`
 		Sonarcloud                *Sonarcloud        `json:"sonarcloud,omitempty"`
 		Destination               *Destination       `json:"destination,omitempty"`
+		// Registry, Repository, Tag and Digest are the parsed parts of the images reference
+		Registry   string `json:"registry,omitempty"`
+		Repository string `json:"repository,omitempty"`
+		Tag        string `json:"tag,omitempty"`
+		Digest     string `json:"digest,omitempty"`
+		// Workloads are the containers running the image
+		Workloads []Workload `json:"workloads,omitempty"`
+		// LatestVersion, VersionsBehind and IsLatest compare the tag with the newest semver tag in the registry
+		LatestVersion  string `json:"latest_version,omitempty"`
+		VersionsBehind *int   `json:"versions_behind,omitempty"`
+		IsLatest       *bool  `json:"is_latest,omitempty"`
+		// Revision and Source are the commit and repository the image was built from
+		Revision string `json:"revision,omitempty"`
+		Source   string `json:"source,omitempty"`
+		// UndeployedCommits are the commits on the default branch of the repo since Revision
+		UndeployedCommits *int `json:"undeployed_commits,omitempty"`
+	}
+	Workload struct {
+		Kind          string `json:"kind"`
+		Name          string `json:"name"`
+		Container     string `json:"container,omitempty"`
+		ContainerType string `json:"container_type"`
+		Replicas      int    `json:"replicas"`
 	}
 	Sonarcloud struct {
 		CodeCoverage struct {
@@ -48,13 +81,20 @@ type (
 
 func (m *Mapper) Decorate(ctx context.Context, rg repoGetter, mg measureGetter, image *Image) (bool, error) {
 
-	repoName, imageName := m.parseImageAndContainerRepo(image.Name)
+	fullName := image.Name
+	repoName, imageName := m.parseImageAndContainerRepo(image.Reference())
 	if repoName != "" {
 		image.Name = imageName
 		image.DockerContainerRepository = repoName
 	}
 
 	status, resolved, keys := m.resolve(imageNamespace, imageName)
+	if status == noMappingFound && imageName != fullName {
+		// mappings can use the full name of the image including the container repo
+		if s, r, k := m.resolve(imageNamespace, fullName); s != noMappingFound {
+			status, resolved, keys = s, r, k
+		}
+	}
 	switch status {
 	case ignored:
 		return false, nil
@@ -113,11 +153,19 @@ func split(repo, defaultOwner string) (string, string) {
 }
 
 // parseImageAndContainerRepo returns the container repo and the image name.
-func (m *Mapper) parseImageAndContainerRepo(name string) (string, string) {
+// Container repos match the registry and the leading components of the repository,
+// the longest matching container repo is used. If none match the full name is returned.
+func (m *Mapper) parseImageAndContainerRepo(ref Reference) (string, string) {
+	name := ref.Name()
+	match := ""
 	for k := range m.containerRepos {
-		if strings.HasPrefix(name, k) {
-			return k, strings.Replace(name, k, "", 1)
+		prefix := strings.TrimSuffix(k, "/")
+		if strings.HasPrefix(name, prefix+"/") && len(prefix) > len(match) {
+			match = prefix
 		}
 	}
+	if match != "" {
+		return match, strings.TrimPrefix(name, match+"/")
+	}
 	return "", name
 }
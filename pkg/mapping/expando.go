@@ -2,6 +2,8 @@ package mapping
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
 	"github.com/mdevilliers/org-scrounger/pkg/mapping/parser"
 )
@@ -14,11 +16,24 @@ const (
 	noMappingFound
 )
 
+// patternRule maps values matching a regex or glob to a key, the key can
+// reference the capture groups of the pattern
+type patternRule struct {
+	pattern *regexp.Regexp
+	key     string
+	ignore  bool
+	// namespace is set if the pattern starts with a namespace e.g. 'image:'
+	namespace string
+}
+
+// namespaces can prefix a value e.g. "image:bar"
+var namespaces = []string{imageNamespace, sonarcloudNamespace}
+
 func (m *Mapper) expand(rules *parser.MappingRuleSet) {
 
 	for _, e := range rules.Entries {
 		if e.Field != nil {
-			if e.Field.Key == "owner" {
+			if e.Field.Key == "owner" && e.Field.Value.String != nil {
 				m.defaultOwner = *(e.Field.Value.String)
 			}
 			if e.Field.Key == "container_repositories" {
@@ -32,8 +47,7 @@ func (m *Mapper) expand(rules *parser.MappingRuleSet) {
 			if e.Mapping.Ignore != nil {
 				ignore := *(e.Mapping.Ignore)
 				if ignore {
-					key := *(e.Mapping.Value.String)
-					m.ignore[key] = true
+					m.expandIgnore(e.Mapping.Value)
 				}
 			} else if e.Mapping.Value != nil {
 				if e.Mapping.Value.Wildcard != nil { //nolint: gocritic
@@ -43,9 +57,15 @@ func (m *Mapper) expand(rules *parser.MappingRuleSet) {
 					v := *(e.Mapping.Value.String)
 					m.reversed[v] = e.Mapping.Key
 					m.keyed[e.Mapping.Key] = []string{v}
+				} else if p := pattern(e.Mapping.Value); p != nil {
+					m.patterns = append(m.patterns, patternRule{pattern: p, key: e.Mapping.Key, namespace: patternNamespace(e.Mapping.Value)})
 				} else if len(e.Mapping.Value.List) != 0 {
 					all := []string{}
 					for _, v := range e.Mapping.Value.List {
+						if p := pattern(v); p != nil {
+							m.patterns = append(m.patterns, patternRule{pattern: p, key: e.Mapping.Key, namespace: patternNamespace(v)})
+							continue
+						}
 						vv := *(v.String)
 						m.reversed[vv] = e.Mapping.Key
 						all = append(all, vv)
@@ -57,25 +77,94 @@ func (m *Mapper) expand(rules *parser.MappingRuleSet) {
 	}
 }
 
+func (m *Mapper) expandIgnore(value *parser.Value) {
+	if p := pattern(value); p != nil {
+		m.patterns = append(m.patterns, patternRule{pattern: p, ignore: true, namespace: patternNamespace(value)})
+		return
+	}
+	if value.String != nil {
+		m.ignore[*(value.String)] = true
+	}
+	for _, v := range value.List {
+		m.expandIgnore(v)
+	}
+}
+
+// pattern returns the compiled regex or glob of a value, if any
+func pattern(value *parser.Value) *regexp.Regexp {
+	switch {
+	case value.Regex != nil:
+		return value.Regex.Regexp
+	case value.Glob != nil:
+		return value.Glob.Regexp
+	}
+	return nil
+}
+
+// patternNamespace returns the namespace the source of a pattern starts with, if any
+func patternNamespace(value *parser.Value) string {
+	source := ""
+	switch {
+	case value.Regex != nil:
+		source = value.Regex.Source
+	case value.Glob != nil:
+		source = value.Glob.Source
+	}
+	for _, ns := range namespaces {
+		if strings.HasPrefix(source, ns+":") {
+			return ns
+		}
+	}
+	return ""
+}
+
+// resolve maps a name to a key. Exact rules take precedence over pattern
+// rules, pattern rules are tried in the order they were declared. Namespaced
+// rules take precedence over rules without a namespace. Namespaced patterns
+// match the namespaced name, other patterns match the name alone so capture
+// groups never include the namespace.
 func (m *Mapper) resolve(namespace, name string) (status, string, []string) {
 
-	needle := name
+	needles := []string{name}
 	if namespace != "" {
-		needle = fmt.Sprintf("%s:%s", namespace, name)
+		needles = []string{fmt.Sprintf("%s:%s", namespace, name), name}
 	}
 
-	_, found := m.ignore[needle]
-	if found {
-		return ignored, name, m.keyed[needle]
-	}
-	v, found := m.reversed[needle]
-	if found {
-		return ok, v, m.keyed[needle]
+	for _, needle := range needles {
+		if _, found := m.ignore[needle]; found {
+			return ignored, name, m.keyed[needle]
+		}
+		if v, found := m.reversed[needle]; found {
+			return ok, v, m.keyed[needle]
+		}
 	}
 
-	// try resolving with no namespace
 	if namespace != "" {
-		return m.resolve("", name)
+		if s, v, keys, found := m.matchPattern(namespace, needles[0], name); found {
+			return s, v, keys
+		}
+	}
+	if s, v, keys, found := m.matchPattern("", name, name); found {
+		return s, v, keys
 	}
 	return noMappingFound, name, m.keyed[name]
 }
+
+// matchPattern tries the pattern rules of the namespace against the needle in the order declared
+func (m *Mapper) matchPattern(namespace, needle, name string) (status, string, []string, bool) {
+	for _, rule := range m.patterns {
+		if rule.namespace != namespace {
+			continue
+		}
+		match := rule.pattern.FindStringSubmatchIndex(needle)
+		if match == nil {
+			continue
+		}
+		if rule.ignore {
+			return ignored, name, m.keyed[needle], true
+		}
+		key := string(rule.pattern.ExpandString(nil, rule.key, needle, match))
+		return ok, key, m.keyed[key], true
+	}
+	return noMappingFound, name, nil, false
+}
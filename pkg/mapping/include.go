@@ -0,0 +1,161 @@
+package mapping
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alecthomas/participle/v2/lexer"
+	"github.com/mdevilliers/org-scrounger/pkg/mapping/parser"
+)
+
+// loader reads a mapping file and any files it includes into a single rule set
+type loader struct {
+	// stack holds the files currently being read, used to detect cycles
+	stack []string
+	// loaded holds the files already read so they are only merged once
+	loaded map[string]bool
+	rules  *parser.MappingRuleSet
+}
+
+func load(path string) (*parser.MappingRuleSet, error) {
+	l := &loader{
+		loaded: map[string]bool{},
+		rules:  &parser.MappingRuleSet{},
+	}
+	if err := l.load(path); err != nil {
+		return nil, err
+	}
+	if err := conflicts(l.rules); err != nil {
+		return nil, err
+	}
+	return l.rules, nil
+}
+
+func (l *loader) load(path string) error {
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("error resolving mapping file: %s :%w", path, err)
+	}
+	for i, p := range l.stack {
+		if p == abs {
+			cycle := append(append([]string{}, l.stack[i:]...), abs)
+			return fmt.Errorf("error including mapping file: cycle %s", strings.Join(cycle, " -> "))
+		}
+	}
+	if l.loaded[abs] {
+		return nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening mapping file: %s :%w", path, err)
+	}
+	defer file.Close()
+
+	rules, err := parser.UnMarshal(path, file)
+	if err != nil {
+		return fmt.Errorf("error reading mapping file: %w", err)
+	}
+
+	l.stack = append(l.stack, abs)
+	defer func() { l.stack = l.stack[:len(l.stack)-1] }()
+	l.loaded[abs] = true
+
+	for _, e := range rules.Entries {
+		if e.Include == nil {
+			l.rules.Entries = append(l.rules.Entries, e)
+			continue
+		}
+		pattern := e.Include.Path
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("error including mapping file at %s: %w", e.Include.Pos, err)
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("error including mapping file at %s: %q matches no files", e.Include.Pos, e.Include.Path)
+		}
+		for _, match := range matches {
+			if err := l.load(match); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// declaration records where a value was mapped
+type declaration struct {
+	key string
+	pos lexer.Position
+}
+
+// conflicts returns an error listing the values mapped to different keys and the
+// fields assigned different values by different files. Values mapped more than
+// once within a file aren't conflicts, as if the file wasn't included.
+func conflicts(rules *parser.MappingRuleSet) error {
+
+	values := map[string][]declaration{}
+	fields := map[string][]declaration{}
+	errs := []error{}
+
+	// declare returns the conflicting declaration from another file, if any
+	declare := func(declared map[string][]declaration, name string, d declaration) *declaration {
+		for _, existing := range declared[name] {
+			if existing.pos.Filename == d.pos.Filename {
+				return nil
+			}
+		}
+		declared[name] = append(declared[name], d)
+		for _, existing := range declared[name] {
+			if existing.key != d.key {
+				return &existing
+			}
+		}
+		return nil
+	}
+
+	for _, e := range rules.Entries {
+		if e.Field != nil && e.Field.Value.String != nil {
+			d := declaration{key: *(e.Field.Value.String), pos: e.Field.Value.Pos}
+			if existing := declare(fields, e.Field.Key, d); existing != nil {
+				errs = append(errs, fmt.Errorf("%s is set to %q at %s and to %q at %s", e.Field.Key, existing.key, existing.pos, d.key, d.pos))
+			}
+		}
+		if e.Mapping == nil || e.Mapping.Value.Wildcard != nil {
+			continue
+		}
+		key := e.Mapping.Key
+		if e.Mapping.Ignore != nil {
+			key = "_"
+		}
+		for _, v := range append([]*parser.Value{e.Mapping.Value}, e.Mapping.Value.List...) {
+			var value string
+			switch {
+			case v.String != nil:
+				value = fmt.Sprintf("%q", *(v.String))
+			case v.Regex != nil:
+				value = fmt.Sprintf("re:%q", v.Regex.Source)
+			case v.Glob != nil:
+				value = fmt.Sprintf("glob:%q", v.Glob.Source)
+			default:
+				continue
+			}
+			d := declaration{key: key, pos: v.Pos}
+			if existing := declare(values, value, d); existing != nil {
+				errs = append(errs, fmt.Errorf("%s is mapped to %q at %s and to %q at %s", value, existing.key, existing.pos, d.key, d.pos))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("error merging mapping files: %w", errors.Join(errs...))
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package mapping
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.Nil(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.Nil(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	return dir
+}
+
+func Test_LoadFromFileMergesIncludes(t *testing.T) {
+
+	dir := writeFiles(t, map[string]string{
+		"mappings.conf": `
+owner = "org-1"
+include "teams/*.conf"
+include "common.conf"
+`,
+		"common.conf": `
+container_repositories = ["registry.example.com"]
+_ > "please/ignore"
+`,
+		"teams/payments.conf": `
+payments > re:"payments-.*"
+include "../common.conf"
+`,
+		"teams/orders.conf": `
+orders > ["orders-api", "orders-worker"]
+`,
+	})
+
+	mapper, err := LoadFromFile(filepath.Join(dir, "mappings.conf"))
+	require.Nil(t, err)
+
+	require.Equal(t, "org-1", mapper.defaultOwner)
+	require.Equal(t, []string{"registry.example.com"}, mapper.ContainerRepositories())
+
+	s, v, _ := mapper.resolve(imageNamespace, "payments-api")
+	require.Equal(t, ok, s)
+	require.Equal(t, "payments", v)
+
+	s, v, _ = mapper.resolve(imageNamespace, "orders-worker")
+	require.Equal(t, ok, s)
+	require.Equal(t, "orders", v)
+
+	s, _, _ = mapper.resolve(imageNamespace, "please/ignore")
+	require.Equal(t, ignored, s)
+}
+
+func Test_LoadFromFileErrors(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		files    map[string]string
+		contains []string
+	}{
+		{
+			desc: "include cycle",
+			files: map[string]string{
+				"mappings.conf": `include "a.conf"`,
+				"a.conf":        `include "b.conf"`,
+				"b.conf":        `include "a.conf"`,
+			},
+			contains: []string{"cycle", "a.conf -> ", "b.conf -> ", "a.conf"},
+		},
+		{
+			desc: "include matches no files",
+			files: map[string]string{
+				"mappings.conf": "\ninclude \"missing/*.conf\"",
+			},
+			contains: []string{"mappings.conf:2:1", `"missing/*.conf" matches no files`},
+		},
+		{
+			desc: "conflicting mappings",
+			files: map[string]string{
+				"mappings.conf": "foo > \"bar\"\ninclude \"team.conf\"",
+				"team.conf":     "\n\nbaz > [\"qux\", \"bar\"]",
+			},
+			contains: []string{`"bar" is mapped to "foo" at `, `mappings.conf:1:7 and to "baz" at `, "team.conf:3:15"},
+		},
+		{
+			desc: "conflicting ignore",
+			files: map[string]string{
+				"mappings.conf": "foo > \"bar\"\ninclude \"team.conf\"",
+				"team.conf":     `_ > "bar"`,
+			},
+			contains: []string{`"bar" is mapped to "foo" at `, `and to "_" at `, "team.conf:1:5"},
+		},
+		{
+			desc: "conflicting patterns",
+			files: map[string]string{
+				"mappings.conf": "foo > glob:\"bar-*\"\ninclude \"team.conf\"",
+				"team.conf":     `baz > glob:"bar-*"`,
+			},
+			contains: []string{`glob:"bar-*" is mapped to "foo" at `, "team.conf:1:7"},
+		},
+		{
+			desc: "conflicting owner",
+			files: map[string]string{
+				"mappings.conf": "owner = \"org-1\"\ninclude \"team.conf\"",
+				"team.conf":     `owner = "org-2"`,
+			},
+			contains: []string{`owner is set to "org-1" at `, `mappings.conf:1:9 and to "org-2" at `, "team.conf:1:9"},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			dir := writeFiles(t, tC.files)
+			_, err := LoadFromFile(filepath.Join(dir, "mappings.conf"))
+			require.NotNil(t, err)
+			for _, c := range tC.contains {
+				require.Contains(t, err.Error(), c)
+			}
+		})
+	}
+}
+
+func Test_LoadFromFileAllowsRepeatedMappings(t *testing.T) {
+
+	dir := writeFiles(t, map[string]string{
+		"mappings.conf": "owner = \"org-1\"\nfoo > \"bar\"\ninclude \"team.conf\"",
+		"team.conf":     "owner = \"org-1\"\nfoo > [\"bar\", \"baz\"]",
+	})
+
+	mapper, err := LoadFromFile(filepath.Join(dir, "mappings.conf"))
+	require.Nil(t, err)
+
+	s, v, _ := mapper.resolve("", "bar")
+	require.Equal(t, ok, s)
+	require.Equal(t, "foo", v)
+}
+
+func Test_LoadFromFileAllowsRepeatedMappingsWithinAFile(t *testing.T) {
+
+	dir := writeFiles(t, map[string]string{
+		"mappings.conf": "owner = \"org-1\"\nfoo > \"bar\"\nbaz > \"bar\"\nowner = \"org-2\"\ninclude \"team.conf\"",
+		"team.conf":     "qux > [\"quux\", \"quux\"]\nquuz > \"quux\"",
+	})
+
+	mapper, err := LoadFromFile(filepath.Join(dir, "mappings.conf"))
+	require.Nil(t, err)
+
+	s, _, _ := mapper.resolve("", "bar")
+	require.Equal(t, ok, s)
+
+	// a single file is unaffected
+	mapper, err = LoadFromFile(filepath.Join(dir, "team.conf"))
+	require.Nil(t, err)
+
+	s, _, _ = mapper.resolve("", "quux")
+	require.Equal(t, ok, s)
+}
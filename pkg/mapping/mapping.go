@@ -2,8 +2,7 @@ package mapping
 
 import (
 	"errors"
-	"fmt"
-	"os"
+	"sort"
 
 	"github.com/mdevilliers/org-scrounger/pkg/mapping/parser"
 )
@@ -13,26 +12,26 @@ type Mapper struct {
 	// reversed holds keys indexed by value
 	reversed map[string]string
 	// keyed holds values for a key
-	keyed          map[string][]string
-	ignore         map[string]interface{}
+	keyed  map[string][]string
+	ignore map[string]interface{}
+	// patterns hold regex and glob rules in the order declared
+	patterns       []patternRule
 	static         map[string]interface{}
 	defaultOwner   string
 	containerRepos map[string]interface{}
 }
 
-// LoadFromFile returns an initilised Mapping instance or an error
+// LoadFromFile returns an initilised Mapping instance or an error.
+// Included files are merged in place, values mapped to different repos
+// are reported as conflicts.
 func LoadFromFile(path string) (*Mapper, error) {
 
 	if path == "" {
 		return nil, errors.New("path to mapping file is empty")
 	}
-	file, err := os.Open(path)
+	rules, err := load(path)
 	if err != nil {
-		return nil, fmt.Errorf("error opening mapping file: %s :%w", path, err)
-	}
-	rules, err := parser.UnMarshal(path, file)
-	if err != nil {
-		return nil, fmt.Errorf("error reading mapping file: %w", err)
+		return nil, err
 	}
 	return New(rules), nil
 }
@@ -61,3 +60,15 @@ func (m *Mapper) Static() []Image {
 
 	return all
 }
+
+// ContainerRepositories returns the sorted set of known container repositories
+func (m *Mapper) ContainerRepositories() []string {
+	all := []string{}
+
+	for k := range m.containerRepos {
+		all = append(all, k)
+	}
+	sort.Strings(all)
+
+	return all
+}
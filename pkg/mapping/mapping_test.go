@@ -107,3 +107,92 @@ needle > ["abc:no", "def:yes", "maybe"]
 	require.Equal(t, ok, s)
 	require.Equal(t, "needle", v)
 }
+
+func Test_PatternsAreResolved(t *testing.T) {
+
+	reader := strings.NewReader(`
+owner = "org-1"
+
+payments > re:"payments-.*"
+payments-legacy > "payments-old"
+team/$1 > glob:"team-*"
+repo-${name} > ["exact", re:"(?P<name>[a-z]+)-svc"]
+first > glob:"dup-*"
+second > glob:"dup-*"
+_ > glob:"third-party/*"
+namespaced > re:"image:ns-.*"
+`)
+	rules, err := parser.UnMarshal("foo", reader)
+	require.Nil(t, err)
+
+	mapper := New(rules)
+
+	testCases := []struct {
+		desc      string
+		namespace string
+		name      string
+		status    status
+		value     string
+	}{
+		{desc: "regex", name: "payments-api", status: ok, value: "payments"},
+		{desc: "exact takes precedence over a pattern", name: "payments-old", status: ok, value: "payments-legacy"},
+		{desc: "glob capture group", name: "team-billing", status: ok, value: "team/billing"},
+		{desc: "named capture group", name: "orders-svc", status: ok, value: "repo-orders"},
+		{desc: "exact value in a list", name: "exact", status: ok, value: "repo-${name}"},
+		{desc: "first declared pattern wins", name: "dup-1", status: ok, value: "first"},
+		{desc: "ignored pattern", name: "third-party/redis", status: ignored, value: "third-party/redis"},
+		{desc: "namespaced pattern", namespace: "image", name: "ns-api", status: ok, value: "namespaced"},
+		{desc: "namespaced image without a namespaced rule", namespace: "image", name: "payments-worker", status: ok, value: "payments"},
+		{desc: "patterns match the whole value", name: "old-payments-api", status: noMappingFound, value: "old-payments-api"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			s, v, _ := mapper.resolve(tC.namespace, tC.name)
+			require.Equal(t, tC.status, s)
+			require.Equal(t, tC.value, v)
+		})
+	}
+}
+
+func Test_PatternsAreDecorated(t *testing.T) {
+
+	reader := strings.NewReader(`
+owner = "org-1"
+
+$1 > re:"(.*)-svc"
+exact > "payments-api"
+namespaced/$1 > re:"image:(ns-.*)"
+team/$1 > glob:"*-api"
+pattern > re:".*-api"
+`)
+	rules, err := parser.UnMarshal("foo", reader)
+	require.Nil(t, err)
+
+	mapper := New(rules)
+
+	testCases := []struct {
+		desc  string
+		image string
+		org   string
+		repo  string
+	}{
+		{desc: "regex capture group", image: "payments-svc", org: "org-1", repo: "payments"},
+		{desc: "glob capture group", image: "orders-api", org: "team", repo: "orders"},
+		{desc: "exact takes precedence over patterns", image: "payments-api", org: "org-1", repo: "exact"},
+		{desc: "namespaced pattern", image: "ns-worker", org: "namespaced", repo: "ns-worker"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			store := &mappingfakes.FakeRepoGetter{}
+			store.GetRepoByNameReturns(gh.RepositorySlim{}, gh.RateLimit{}, nil)
+
+			found, err := mapper.Decorate(context.Background(), store, nil, &Image{Name: tC.image})
+			require.Nil(t, err)
+			require.True(t, found)
+
+			_, org, repo := store.GetRepoByNameArgsForCall(0)
+			require.Equal(t, tC.org, org)
+			require.Equal(t, tC.repo, repo)
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"fmt"
 	"io"
+	"regexp"
+	"strings"
 
 	"github.com/alecthomas/participle/v2"
 	"github.com/alecthomas/participle/v2/lexer"
@@ -12,13 +15,22 @@ type MappingRuleSet struct {
 	Entries []*Entry `parser:"@@*"`
 }
 
-// Entry can either be a comment, field (assignment) or a mapping
+// Entry can either be a comment, include, field (assignment) or a mapping
 type Entry struct {
 	Comment *string  `parser:"@Comment"`
+	Include *Include `parser:"| @@"`
 	Field   *Field   `parser:"| @@"`
 	Mapping *Mapping `parser:"| @@"`
 }
 
+// Include references another mapping file or a glob of mapping files,
+// relative to the including file
+type Include struct {
+	Pos lexer.Position
+
+	Path string `parser:"'include' @String"`
+}
+
 // Field represents an assigned variable
 type Field struct {
 	Key   string `parser:"@Ident '='"`
@@ -26,28 +38,115 @@ type Field struct {
 }
 
 // Mapping represents a relationship between the
-// left and right values
+// left and right values. The key can reference the capture
+// groups of a pattern value e.g. $1 or ${name}
 type Mapping struct {
 	Key    string `parser:"( @Ident"`
 	Ignore *bool  `parser:" | @Wildcard )"`
 	Value  *Value `parser:"'>' @@"`
 }
 
-// Value can either be a string or a list of values, or a wildcard
+// Value can either be a string, a regular expression, a glob, a list of values, or a wildcard
 type Value struct {
+	Pos lexer.Position
+
 	String   *string  `parser:"@String"`
+	Regex    *Pattern `parser:" | 're' ':' @String"`
+	Glob     *Glob    `parser:" | 'glob' ':' @String"`
 	List     []*Value `parser:" | '[' ( @@ ( ',' @@ )* )? ']'"`
 	Wildcard *bool    `parser:" | @Wildcard"`
 }
 
+// Pattern is a regular expression matching the whole of a value
+type Pattern struct {
+	Source string
+	Regexp *regexp.Regexp
+}
+
+// Capture records the source of the regular expression, it is compiled once parsed
+func (p *Pattern) Capture(values []string) error {
+	p.Source = values[0]
+	return nil
+}
+
+func (p *Pattern) compile(expr string) error {
+	re, err := regexp.Compile(`^(?:` + expr + `)$`)
+	if err != nil {
+		return fmt.Errorf("error compiling pattern %q: %w", p.Source, err)
+	}
+	p.Regexp = re
+	return nil
+}
+
+// Glob is a Pattern where '*' matches any characters except '/' and
+// '?' matches a single character except '/'. Each wildcard is a capture group.
+type Glob struct {
+	Pattern
+}
+
+// Capture records the source of the glob, it is compiled once parsed
+func (g *Glob) Capture(values []string) error {
+	g.Source = values[0]
+	return nil
+}
+
+// validate returns an error if a pattern is assigned to the field, fields only hold strings
+func (f *Field) validate() error {
+	values := append([]*Value{f.Value}, f.Value.List...)
+	for _, v := range values {
+		if v.Regex != nil || v.Glob != nil {
+			return participle.Errorf(v.Pos, "%s can't be assigned a pattern", f.Key)
+		}
+		if v != f.Value && v.String == nil {
+			return participle.Errorf(v.Pos, "%s can only be assigned a list of strings", f.Key)
+		}
+	}
+	return nil
+}
+
+// compile compiles the patterns of the value and any values in its list
+func (v *Value) compile() error {
+	var err error
+	switch {
+	case v.Regex != nil:
+		err = v.Regex.compile(v.Regex.Source)
+	case v.Glob != nil:
+		err = v.Glob.compile(globToRegexp(v.Glob.Source))
+	}
+	if err != nil {
+		return participle.Errorf(v.Pos, "%s", err)
+	}
+	for _, vv := range v.List {
+		if err := vv.compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(`([^/]*)`)
+		case '?':
+			b.WriteString(`([^/])`)
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return b.String()
+}
+
 var (
 	parser = participle.MustBuild[MappingRuleSet](
 		participle.Lexer(
 			lexer.MustSimple([]lexer.SimpleRule{
-				{Name: `Ident`, Pattern: `[a-zA-Z\d][a-zA-Z_\-\/\d]*`},
+				{Name: `Ident`, Pattern: `[a-zA-Z\d$][a-zA-Z_\-\/\d${}]*`},
 				{Name: "String", Pattern: `"[^"]*"`},
 				{Name: "Wildcard", Pattern: `[_]`},
-				{Name: "Punct", Pattern: `\[|]|[-!()+/*=,>]`},
+				{Name: "Punct", Pattern: `\[|]|[-!()+/*=,>:]`},
 				{Name: "Comment", Pattern: `#[^\n]+`},
 				{Name: "whitespace", Pattern: `\s+`},
 			}),
@@ -58,5 +157,20 @@ var (
 
 // UnMarshal returns a parsed representation of the rules or an error
 func UnMarshal(filename string, in io.Reader) (*MappingRuleSet, error) {
-	return parser.Parse(filename, in)
+	rules, err := parser.Parse(filename, in)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range rules.Entries {
+		switch {
+		case e.Field != nil:
+			err = e.Field.validate()
+		case e.Mapping != nil:
+			err = e.Mapping.Value.compile()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
 }
@@ -34,3 +34,112 @@ _ > "third-party/something/something"
 	// repr.Println(o, repr.Indent("  "), repr.OmitEmpty(true))
 	require.Len(t, o.Entries, 14) // includes comments
 }
+
+func Test_ParsePatterns(t *testing.T) {
+
+	testFile := `
+payments > re:"payments-.*"
+team/$1 > glob:"team-*"
+repo-${name} > ["exact", re:"(?P<name>[a-z]+)-svc"]
+_ > glob:"third-party/*"
+`
+	r := strings.NewReader(testFile)
+	o, err := UnMarshal("test", r)
+	require.Nil(t, err)
+	require.Len(t, o.Entries, 4)
+
+	m := o.Entries[0].Mapping
+	require.Equal(t, "payments", m.Key)
+	require.Equal(t, "payments-.*", m.Value.Regex.Source)
+	require.True(t, m.Value.Regex.Regexp.MatchString("payments-api"))
+	require.False(t, m.Value.Regex.Regexp.MatchString("old-payments-api"))
+
+	m = o.Entries[1].Mapping
+	require.Equal(t, "team/$1", m.Key)
+	require.Equal(t, "team-*", m.Value.Glob.Source)
+	require.True(t, m.Value.Glob.Regexp.MatchString("team-a"))
+	require.False(t, m.Value.Glob.Regexp.MatchString("team-a/b"))
+
+	m = o.Entries[2].Mapping
+	require.Equal(t, "repo-${name}", m.Key)
+	require.Len(t, m.Value.List, 2)
+	require.Equal(t, "exact", *m.Value.List[0].String)
+	require.NotNil(t, m.Value.List[1].Regex)
+
+	m = o.Entries[3].Mapping
+	require.NotNil(t, m.Ignore)
+	require.NotNil(t, m.Value.Glob)
+}
+
+func Test_ParseInvalidPattern(t *testing.T) {
+
+	r := strings.NewReader(`
+payments > re:"payments-("
+`)
+	_, err := UnMarshal("test", r)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "test:2:")
+	require.Contains(t, err.Error(), "payments-(")
+}
+
+func Test_GlobToRegexp(t *testing.T) {
+	testCases := []struct {
+		desc  string
+		glob  string
+		value string
+		match bool
+	}{
+		{desc: "literal", glob: "foo.bar", value: "foo.bar", match: true},
+		{desc: "dots are literal", glob: "foo.bar", value: "fooxbar", match: false},
+		{desc: "star", glob: "foo-*", value: "foo-api", match: true},
+		{desc: "star doesn't match a separator", glob: "foo-*", value: "foo-api/v2", match: false},
+		{desc: "question mark", glob: "foo-?", value: "foo-1", match: true},
+		{desc: "question mark matches one character", glob: "foo-?", value: "foo-12", match: false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			v := &Value{Glob: &Glob{Pattern{Source: tC.glob}}}
+			require.Nil(t, v.compile())
+			g := v.Glob
+			require.Equal(t, tC.match, g.Regexp.MatchString(tC.value))
+		})
+	}
+}
+
+func Test_ParseInclude(t *testing.T) {
+
+	r := strings.NewReader(`
+include "teams/*.conf"
+include "../common.conf"
+include > "include-service"
+`)
+	o, err := UnMarshal("test", r)
+	require.Nil(t, err)
+	require.Len(t, o.Entries, 3)
+
+	require.Equal(t, "teams/*.conf", o.Entries[0].Include.Path)
+	require.Equal(t, 2, o.Entries[0].Include.Pos.Line)
+	require.Equal(t, "../common.conf", o.Entries[1].Include.Path)
+	// include is still a valid repo name
+	require.Equal(t, "include", o.Entries[2].Mapping.Key)
+}
+
+func Test_ParseInvalidFields(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		input    string
+		contains string
+	}{
+		{desc: "regex", input: `owner = re:"x"`, contains: "test:1:9: owner can't be assigned a pattern"},
+		{desc: "regex in a list", input: `container_repositories = ["a", re:"x"]`, contains: "test:1:32: container_repositories can't be assigned a pattern"},
+		{desc: "glob in a list", input: `container_repositories = [glob:"x"]`, contains: "test:1:27: container_repositories can't be assigned a pattern"},
+		{desc: "nested list", input: `container_repositories = [["a"]]`, contains: "test:1:27: container_repositories can only be assigned a list of strings"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, err := UnMarshal("test", strings.NewReader(tC.input))
+			require.NotNil(t, err)
+			require.Contains(t, err.Error(), tC.contains)
+		})
+	}
+}
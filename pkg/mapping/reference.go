@@ -0,0 +1,138 @@
+package mapping
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// pathComponent is a component of a repository path
+	pathComponent = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*$`)
+	tagPattern    = regexp.MustCompile(`^[\w][\w.-]{0,127}$`)
+	digestPattern = regexp.MustCompile(`^[a-z0-9]+(?:[.+_-][a-z0-9]+)*:[a-zA-Z0-9=_-]{32,}$`)
+)
+
+// Reference is a parsed OCI image reference e.g. 'registry:5000/team/app:1.2@sha256:...'
+type Reference struct {
+	// Registry is empty if the reference doesn't specify one
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses an image reference. The registry is only set if the first
+// component of the name looks like a host i.e. it contains a '.' or ':' or is 'localhost'.
+func ParseReference(s string) (Reference, error) {
+
+	ref := Reference{}
+	name := strings.TrimSpace(s)
+	if name == "" {
+		return ref, fmt.Errorf("error parsing image reference: empty reference")
+	}
+
+	if i := strings.Index(name, "@"); i >= 0 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+		if !digestPattern.MatchString(ref.Digest) {
+			return ref, fmt.Errorf("error parsing image reference '%s': invalid digest", s)
+		}
+	}
+
+	// a tag follows the last ':' after the last '/' so a registry port isn't mistaken for one
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+		if !tagPattern.MatchString(ref.Tag) {
+			return ref, fmt.Errorf("error parsing image reference '%s': invalid tag", s)
+		}
+	}
+
+	if i := strings.Index(name, "/"); i >= 0 {
+		host := name[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			ref.Registry = host
+			name = name[i+1:]
+		}
+	}
+	ref.Repository = name
+
+	for _, c := range strings.Split(ref.Repository, "/") {
+		if !pathComponent.MatchString(c) {
+			return ref, fmt.Errorf("error parsing image reference '%s': invalid repository", s)
+		}
+	}
+	return ref, nil
+}
+
+// Name returns the registry and repository
+func (r Reference) Name() string {
+	if r.Registry == "" {
+		return r.Repository
+	}
+	return fmt.Sprintf("%s/%s", r.Registry, r.Repository)
+}
+
+// Version returns the tag and digest or 'unknown' if neither are specified
+func (r Reference) Version() string {
+	switch {
+	case r.Tag != "" && r.Digest != "":
+		return fmt.Sprintf("%s@%s", r.Tag, r.Digest)
+	case r.Digest != "":
+		return r.Digest
+	case r.Tag != "":
+		return r.Tag
+	}
+	return "unknown"
+}
+
+// String returns the reference in its canonical form
+func (r Reference) String() string {
+	s := r.Name()
+	if r.Tag != "" {
+		s = fmt.Sprintf("%s:%s", s, r.Tag)
+	}
+	if r.Digest != "" {
+		s = fmt.Sprintf("%s@%s", s, r.Digest)
+	}
+	return s
+}
+
+// NewImage returns an Image for the reference. References that can't be
+// parsed e.g. unrendered templates are returned as the name of the image.
+func NewImage(reference string) Image {
+	ref, err := ParseReference(reference)
+	if err != nil {
+		return Image{
+			Name:    strings.TrimSpace(reference),
+			Version: "unknown",
+		}
+	}
+	return Image{
+		Name:       ref.Name(),
+		Version:    ref.Version(),
+		Registry:   ref.Registry,
+		Repository: ref.Repository,
+		Tag:        ref.Tag,
+		Digest:     ref.Digest,
+	}
+}
+
+// Reference returns the parsed reference of the image, falling back to
+// parsing the name for images not created from a reference
+func (i Image) Reference() Reference {
+	if i.Repository != "" {
+		return Reference{
+			Registry:   i.Registry,
+			Repository: i.Repository,
+			Tag:        i.Tag,
+			Digest:     i.Digest,
+		}
+	}
+	ref, err := ParseReference(i.Name)
+	if err != nil {
+		return Reference{Repository: i.Name}
+	}
+	return ref
+}
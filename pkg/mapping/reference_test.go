@@ -0,0 +1,215 @@
+package mapping
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mdevilliers/org-scrounger/pkg/gh"
+	"github.com/mdevilliers/org-scrounger/pkg/mapping/mappingfakes"
+	"github.com/mdevilliers/org-scrounger/pkg/mapping/parser"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_ParseReference(t *testing.T) {
+
+	digest := "sha256:" + strings.Repeat("a1", 32)
+
+	testCases := []struct {
+		desc      string
+		reference string
+		expected  Reference
+		version   string
+		err       bool
+	}{
+		{
+			desc:      "name only",
+			reference: "nginx",
+			expected:  Reference{Repository: "nginx"},
+			version:   "unknown",
+		},
+		{
+			desc:      "name and tag",
+			reference: "nginx:1.25",
+			expected:  Reference{Repository: "nginx", Tag: "1.25"},
+			version:   "1.25",
+		},
+		{
+			desc:      "registry with port",
+			reference: "registry:5000/team/app:1.2",
+			expected:  Reference{Registry: "registry:5000", Repository: "team/app", Tag: "1.2"},
+			version:   "1.2",
+		},
+		{
+			desc:      "registry with port and no tag",
+			reference: "registry:5000/team/app",
+			expected:  Reference{Registry: "registry:5000", Repository: "team/app"},
+			version:   "unknown",
+		},
+		{
+			desc:      "localhost registry",
+			reference: "localhost/app",
+			expected:  Reference{Registry: "localhost", Repository: "app"},
+			version:   "unknown",
+		},
+		{
+			desc:      "docker hub",
+			reference: "docker.io/library/nginx",
+			expected:  Reference{Registry: "docker.io", Repository: "library/nginx"},
+			version:   "unknown",
+		},
+		{
+			desc:      "nested repository",
+			reference: "gcr.io/a/b/c:tag",
+			expected:  Reference{Registry: "gcr.io", Repository: "a/b/c", Tag: "tag"},
+			version:   "tag",
+		},
+		{
+			desc:      "first component without a host is part of the repository",
+			reference: "team/app:v1",
+			expected:  Reference{Repository: "team/app", Tag: "v1"},
+			version:   "v1",
+		},
+		{
+			desc:      "digest",
+			reference: "app@" + digest,
+			expected:  Reference{Repository: "app", Digest: digest},
+			version:   digest,
+		},
+		{
+			desc:      "tag and digest",
+			reference: "ghcr.io/org/app:1.0@" + digest,
+			expected:  Reference{Registry: "ghcr.io", Repository: "org/app", Tag: "1.0", Digest: digest},
+			version:   "1.0@" + digest,
+		},
+		{
+			desc:      "surrounding whitespace",
+			reference: " app:1.0 ",
+			expected:  Reference{Repository: "app", Tag: "1.0"},
+			version:   "1.0",
+		},
+		{desc: "empty", reference: "", err: true},
+		{desc: "uppercase repository", reference: "App:1.0", err: true},
+		{desc: "invalid digest", reference: "app@sha256:abc", err: true},
+		{desc: "invalid tag", reference: "app:-1", err: true},
+		{desc: "empty component", reference: "gcr.io//app", err: true},
+		{desc: "unrendered template", reference: "{{ .Values.image }}", err: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			ref, err := ParseReference(tC.reference)
+			if tC.err {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tC.expected, ref)
+			require.Equal(t, tC.version, ref.Version())
+			require.Equal(t, strings.TrimSpace(tC.reference), ref.String())
+		})
+	}
+}
+
+func Test_NewImage(t *testing.T) {
+
+	image := NewImage("registry:5000/team/app:1.2")
+	require.Equal(t, "registry:5000/team/app", image.Name)
+	require.Equal(t, "1.2", image.Version)
+	require.Equal(t, "registry:5000", image.Registry)
+	require.Equal(t, "team/app", image.Repository)
+	require.Equal(t, "1.2", image.Tag)
+
+	// references that can't be parsed are kept as the name
+	image = NewImage("{{ .Values.image }}")
+	require.Equal(t, "{{ .Values.image }}", image.Name)
+	require.Equal(t, "unknown", image.Version)
+	require.Equal(t, Reference{Repository: "{{ .Values.image }}"}, image.Reference())
+}
+
+func Test_ContainerRepositoryIsParsedFromReference(t *testing.T) {
+
+	reader := strings.NewReader(`
+owner = "org-1"
+
+container_repositories = [
+  "registry:5000",
+  "registry:5000/team",
+  "gcr.io/project/"
+]
+`)
+	rules, err := parser.UnMarshal("foo", reader)
+	require.Nil(t, err)
+
+	mapper := New(rules)
+
+	testCases := []struct {
+		reference string
+		repo      string
+		name      string
+	}{
+		// the longest matching container repository is used
+		{reference: "registry:5000/team/app:1.2", repo: "registry:5000/team", name: "app"},
+		{reference: "registry:5000/other/app:1.2", repo: "registry:5000", name: "other/app"},
+		// a prefix has to match whole components
+		{reference: "registry:5000/teams/app", repo: "registry:5000", name: "teams/app"},
+		{reference: "gcr.io/project/app@sha256:" + strings.Repeat("0", 64), repo: "gcr.io/project", name: "app"},
+		// unknown registries aren't stripped
+		{reference: "gcr.io/project-2/app", repo: "", name: "gcr.io/project-2/app"},
+		{reference: "quay.io/prometheus/node-exporter:v1.0.0", repo: "", name: "quay.io/prometheus/node-exporter"},
+		{reference: "app:1.0", repo: "", name: "app"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.reference, func(t *testing.T) {
+			repo, name := mapper.parseImageAndContainerRepo(NewImage(tC.reference).Reference())
+			require.Equal(t, tC.repo, repo)
+			require.Equal(t, tC.name, name)
+		})
+	}
+}
+
+func Test_RegistryQualifiedMappings(t *testing.T) {
+
+	reader := strings.NewReader(`
+owner = "org-1"
+
+container_repositories = ["registry:5000"]
+
+_ > "quay.io/prometheus/node-exporter"
+svc > "gcr.io/proj/svc"
+mapped > "registry:5000/full"
+`)
+	rules, err := parser.UnMarshal("foo", reader)
+	require.Nil(t, err)
+
+	mapper := New(rules)
+	ctx := context.Background()
+
+	store := &mappingfakes.FakeRepoGetter{}
+	store.GetRepoByNameReturns(gh.RepositorySlim{}, gh.RateLimit{}, nil)
+
+	image := NewImage("quay.io/prometheus/node-exporter:v1.0.0")
+	found, err := mapper.Decorate(ctx, store, nil, &image)
+	require.Nil(t, err)
+	require.False(t, found)
+	require.Equal(t, 0, store.GetRepoByNameCallCount())
+
+	image = NewImage("gcr.io/proj/svc:1.0.0")
+	found, err = mapper.Decorate(ctx, store, nil, &image)
+	require.Nil(t, err)
+	require.True(t, found)
+	require.Equal(t, "gcr.io/proj/svc", image.Name)
+	require.Equal(t, "", image.DockerContainerRepository)
+	_, org, r := store.GetRepoByNameArgsForCall(0)
+	require.Equal(t, "org-1", org)
+	require.Equal(t, "svc", r)
+
+	// the full name is used if the name without the container repo isn't mapped
+	image = NewImage("registry:5000/full:1.0.0")
+	found, err = mapper.Decorate(ctx, store, nil, &image)
+	require.Nil(t, err)
+	require.True(t, found)
+	require.Equal(t, "full", image.Name)
+	require.Equal(t, "registry:5000", image.DockerContainerRepository)
+	_, _, r = store.GetRepoByNameArgsForCall(1)
+	require.Equal(t, "mapped", r)
+}
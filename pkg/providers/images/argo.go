@@ -6,64 +6,153 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/mdevilliers/org-scrounger/pkg/exec"
+	"github.com/alitto/pond"
 	"github.com/mdevilliers/org-scrounger/pkg/mapping"
 	"github.com/rs/zerolog/log"
 	"gopkg.in/yaml.v3"
 )
 
+// maxArgoDepth limits how deep app-of-apps are followed
+const maxArgoDepth = 10
+
 type argoProvider struct {
-	deleteCacheOnExit bool
-	paths             []string
+	options ArgoOptions
+	paths   []string
+}
+
+// ArgoOptions configure how applications are checked out and rendered
+type ArgoOptions struct {
+	// DeleteCacheOnExit checks out to a temporary directory deleted once the images are found
+	DeleteCacheOnExit bool
+	// UseKustomizeBinary shells out to the kustomize binary rather than building in-process
+	UseKustomizeBinary bool
+	// GithubURL is used to resolve repoURLs that aren't absolute e.g. 'owner/repo'
+	GithubURL string
+	// CheckoutDir is where git checkouts and charts are cached between runs
+	CheckoutDir string
+	// Concurrency is the maximum number of checkouts made in parallel
+	Concurrency int
+	// SparseCheckout only checks out the path of each application
+	SparseCheckout bool
+	// LockTimeout is how long to wait for another run using the checkout directory
+	LockTimeout time.Duration
+	// ImagePaths are extra JSONPaths to images in CRDs
+	ImagePaths []string
 }
 
-func NewArgo(deleteCacheOnExit bool, paths ...string) *argoProvider {
+func NewArgo(options ArgoOptions, paths ...string) *argoProvider {
+	if options.Concurrency < 1 {
+		options.Concurrency = 1
+	}
 	return &argoProvider{
-		deleteCacheOnExit: deleteCacheOnExit,
-		paths:             paths,
+		options: options,
+		paths:   paths,
 	}
 }
 
 type ArgoApplication struct {
-	Kind string
+	Kind     string
+	Metadata struct {
+		Name      string
+		Namespace string
+	}
 	Spec struct {
 		Destination struct {
 			Namespace string
 		}
-		Source struct {
-			RepoURL        string `yaml:"repoURL"`
-			Path           string
-			TargetRevision string `yaml:"targetRevision"`
-			Helm           *struct {
-				ReleaseName string
-				Parameters  []struct {
-					Name  string
-					Value string
-				}
-				Values string // :shrug
-			}
-		}
+		Source ArgoSource
+		// Sources is used by multi-source applications instead of Source
+		Sources []ArgoSource
+	}
+}
+
+type ArgoSource struct {
+	RepoURL        string `yaml:"repoURL"`
+	Path           string
+	TargetRevision string `yaml:"targetRevision"`
+	// Ref names the source so other sources can reference its files e.g. '$values/path/to/values.yaml'
+	Ref string
+	// Chart is set when the source is a chart in the helm repository at RepoURL
+	Chart     string
+	Directory *struct {
+		Recurse bool
+	}
+	Helm *ArgoHelm
+}
+
+type ArgoHelm struct {
+	// ReleaseName defaults to the name of the application
+	ReleaseName string   `yaml:"releaseName"`
+	ValueFiles  []string `yaml:"valueFiles"`
+	Parameters  []struct {
+		Name        string
+		Value       string
+		ForceString bool `yaml:"forceString"`
+	}
+	Values string // :shrug
+	// ValuesObject replaces Values when both are set
+	ValuesObject map[string]interface{} `yaml:"valuesObject"`
+}
+
+// Sources returns the sources of a single or multi-source application
+func (a ArgoApplication) Sources() []ArgoSource {
+	if len(a.Spec.Sources) > 0 {
+		return a.Spec.Sources
+	}
+	if a.Spec.Source.RepoURL == "" {
+		return nil
 	}
+	return []ArgoSource{a.Spec.Source}
+}
+
+// revision returns the revision to checkout defaulting to HEAD
+func (s ArgoSource) revision() string {
+	if s.TargetRevision == "" {
+		return "HEAD"
+	}
+	return s.TargetRevision
 }
 
 func (a *argoProvider) Images(ctx context.Context) ([]mapping.Image, error) {
 	all := []mapping.Image{}
 
-	// use the temp dir as the root of any checkout
-	directory := os.TempDir()
-	directory = path.Join(directory, "scrng")
+	directory := a.options.CheckoutDir
+	if a.options.DeleteCacheOnExit {
+		// the checkout directory is shared with other runs so use one for this run only
+		tmp, err := os.MkdirTemp("", "scrng-checkouts-")
+		if err != nil {
+			return nil, fmt.Errorf("error creating checkout directory: %w", err)
+		}
+		directory = tmp
+		defer os.RemoveAll(directory)
+	}
+
+	checkouts := newCheckoutCache(directory, a.options.SparseCheckout)
+	if a.options.LockTimeout > 0 {
+		checkouts.lockTimeout = a.options.LockTimeout
+	}
+	defer checkouts.close()
+
+	pool := pond.New(a.options.Concurrency, 0)
+	defer pool.StopAndWait()
 
-	if a.deleteCacheOnExit {
-		defer func() {
-			os.RemoveAll(directory)
-		}()
+	w := &argoWalk{
+		provider:  a,
+		checkouts: checkouts,
+		charts:    path.Join(directory, "charts"),
+		pool:      pool,
 	}
 
 	for _, p := range a.paths {
 
-		data, err := os.ReadFile(p)
+		// each root is walked separately so applications with the same name in different roots are found
+		w.visited = map[string]bool{}
+
+		content, err := readManifests(p, true)
 		if errors.Is(err, os.ErrNotExist) {
 			log.Info().Msgf("file does not exist: %s", p)
 			continue
@@ -72,63 +161,284 @@ func (a *argoProvider) Images(ctx context.Context) ([]mapping.Image, error) {
 			return nil, fmt.Errorf("error loading YAML file: %w", err)
 		}
 
-		var app ArgoApplication
-		if err = yaml.Unmarshal(data, &app); err != nil {
-			return nil, fmt.Errorf("error unmarshalling YAML: %w", err)
+		images, err := w.resolve(ctx, content, "unknown", 0)
+		if err != nil {
+			return nil, err // already wrapped
 		}
 
-		root, err := cachedGithubCheckout(directory, app.Spec.Source.RepoURL, app.Spec.Source.TargetRevision)
-		if err != nil {
-			return nil, fmt.Errorf("error checking out %s@%s: %w", app.Spec.Source.RepoURL, app.Spec.Source.TargetRevision, err)
+		all = append(all, images...)
+
+	}
+	return all, nil
+}
+
+// argoWalk follows applications, application sets and app-of-apps
+// remembering the applications already visited from the current root
+type argoWalk struct {
+	provider  *argoProvider
+	checkouts *checkoutCache
+	charts    string
+	// pool bounds the checkouts made in parallel
+	pool    *pond.WorkerPool
+	visited map[string]bool
+}
+
+// resolve returns the images in the documents following any applications
+// or application sets found
+func (w *argoWalk) resolve(ctx context.Context, content, namespace string, depth int) ([]mapping.Image, error) { //nolint:funlen
+
+	if depth > maxArgoDepth {
+		return nil, fmt.Errorf("error following applications: more than %d levels deep", maxArgoDepth)
+	}
+
+	all := []mapping.Image{}
+	manifests := []*yaml.Node{}
+	apps := []ArgoApplication{}
+
+	documents, err := parseDocuments(content)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, doc := range documents {
+
+		var meta struct {
+			APIVersion string `yaml:"apiVersion"`
+			Kind       string
+		}
+		if err := doc.Decode(&meta); err != nil {
+			return nil, fmt.Errorf("error unmarshalling YAML: %w", err)
+		}
+		if !strings.HasPrefix(meta.APIVersion, "argoproj.io/") {
+			manifests = append(manifests, doc)
+			continue
 		}
-		var content string
 
-		if app.Spec.Source.Helm != nil {
-			content, err = runHelm(root, app)
-			if err != nil {
-				return nil, fmt.Errorf("error running helm template: %s, output: %s : %w", root, content, err)
+		switch meta.Kind {
+		case "Application":
+			var app ArgoApplication
+			if err := doc.Decode(&app); err != nil {
+				return nil, fmt.Errorf("error unmarshalling YAML: %w", err)
 			}
-		} else {
-
-			// assume there is a kustomise file available
-			p := path.Join(root, app.Spec.Source.Path)
-			content, err = runKustomize(p)
+			apps = append(apps, app)
+		case "ApplicationSet":
+			var set ArgoApplicationSet
+			if err := doc.Decode(&set); err != nil {
+				return nil, fmt.Errorf("error unmarshalling YAML: %w", err)
+			}
+			expanded, err := w.expand(ctx, set)
 			if err != nil {
-				return nil, fmt.Errorf("error running kustomize: %w at %s", err, p)
+				return nil, fmt.Errorf("error expanding application set %s: %w", set.Metadata.Name, err)
 			}
+			apps = append(apps, expanded...)
 		}
+	}
 
-		images, err := resolveImages(content, app.Spec.Destination.Namespace)
+	if err := w.prefetch(ctx, apps); err != nil {
+		return nil, err
+	}
+	for _, app := range apps {
+		images, err := w.application(ctx, app, depth)
 		if err != nil {
-			return nil, fmt.Errorf("error extracting images: %w", err)
+			return nil, err
 		}
+		all = append(all, images...)
+	}
 
+	if len(manifests) > 0 {
+		images, err := resolveDocumentImages(manifests, namespace, w.provider.options.ImagePaths...)
+		if err != nil {
+			return nil, fmt.Errorf("error extracting images: %w", err)
+		}
 		all = append(all, images...)
+	}
+	return all, nil
+}
+
+// application renders each of the sources of the application
+func (w *argoWalk) application(ctx context.Context, app ArgoApplication, depth int) ([]mapping.Image, error) {
+
+	if app.Metadata.Name != "" {
+		key := fmt.Sprintf("%s/%s", app.Metadata.Namespace, app.Metadata.Name)
+		if w.visited[key] {
+			log.Info().Msgf("application already visited: %s", key)
+			return nil, nil
+		}
+		w.visited[key] = true
+	}
+
+	sources := app.Sources()
 
+	// sources with a ref can be referenced by the other sources
+	refs := map[string]string{}
+	for _, source := range sources {
+		if source.Ref == "" {
+			continue
+		}
+		// files anywhere in the source can be referenced
+		root, err := w.checkout(ctx, source.RepoURL, source.revision())
+		if err != nil {
+			return nil, err
+		}
+		refs["$"+source.Ref] = root
+	}
+
+	all := []mapping.Image{}
+	var err error
+	for _, source := range sources {
+
+		// sources only used as a ref don't produce any manifests
+		if source.Ref != "" && source.Path == "" {
+			continue
+		}
+
+		var root string
+		if source.Chart != "" {
+			root, err = pullHelmChart(w.charts, source.RepoURL, source.Chart, source.TargetRevision)
+		} else {
+			root, err = w.checkout(ctx, source.RepoURL, source.revision(), source.Path)
+		}
+		if err != nil {
+			return nil, err
+		}
+		content, err := w.render(root, source, refs, app)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering application %s: %w", app.Metadata.Name, err)
+		}
+
+		images, err := w.resolve(ctx, content, app.Spec.Destination.Namespace, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, images...)
 	}
 	return all, nil
 }
 
-// runs Helm in the root directory returning the inflated output or an error
-func runHelm(root string, app ArgoApplication) (string, error) {
+// render returns the manifests of the source using helm, kustomize or
+// a directory of plain manifests
+func (w *argoWalk) render(root string, source ArgoSource, refs map[string]string, app ArgoApplication) (string, error) {
 
-	args := []string{
-		"template",
+	p := path.Join(root, source.Path)
+
+	if _, err := os.Stat(path.Join(p, "Chart.yaml")); source.Chart != "" || source.Helm != nil || err == nil {
+		return renderArgoHelm(root, source, refs, app)
 	}
 
-	for _, p := range app.Spec.Source.Helm.Parameters {
+	if kustomizationFile(p) != p {
+		return buildKustomization(p, w.provider.options.UseKustomizeBinary)
+	}
 
-		v := p.Value
+	recurse := source.Directory != nil && source.Directory.Recurse
+	content, err := readManifests(p, recurse)
+	if err != nil {
+		return "", fmt.Errorf("error reading manifests: %w at %s", err, p)
+	}
+	return content, nil
+}
 
-		// in Helm, comma separated strings need to be escaped and wrapped in "" :shrug
-		if strings.Index(p.Value, ",") > 0 {
-			v = strings.ReplaceAll(p.Value, ",", "\\,")
-			v = fmt.Sprintf("\"%s\"", v)
+// prefetch checks out the sources of the applications in parallel
+func (w *argoWalk) prefetch(ctx context.Context, apps []ArgoApplication) error {
+	group, _ := w.pool.GroupContext(ctx)
+	for _, app := range apps {
+		for _, source := range app.Sources() {
+			source := source
+			if source.Chart != "" {
+				continue
+			}
+			group.Submit(func() error {
+				paths := []string{source.Path}
+				if source.Ref != "" {
+					paths = nil
+				}
+				_, err := w.checkout(ctx, source.RepoURL, source.revision(), paths...)
+				return err
+			})
 		}
+	}
+	return group.Wait()
+}
 
-		args = append(args, "--set", fmt.Sprintf("%s=%s", p.Name, v))
+// checkout returns the directory of the repository at revision. If sparse checkouts
+// are enabled only the paths are checked out, all paths if none are specified.
+func (w *argoWalk) checkout(ctx context.Context, repoURL, revision string, paths ...string) (string, error) {
+	root, err := w.checkouts.checkout(ctx, resolveRepoURL(w.provider.options.GithubURL, repoURL), revision, paths...)
+	if err != nil {
+		return "", fmt.Errorf("error checking out %s@%s: %w", repoURL, revision, err)
 	}
-	if app.Spec.Source.Helm.Values != "" {
+	return root, nil
+}
+
+// readManifests returns the manifests at p, either a file or a directory
+func readManifests(p string, recurse bool) (string, error) {
+	excludes := []string{}
+	if !recurse {
+		// paths in sub directories contain a separator
+		excludes = append(excludes, "*/**")
+	}
+	m, err := NewManifests(nil, excludes, nil, p)
+	if err != nil {
+		return "", err
+	}
+	files, err := m.files(p)
+	if err != nil {
+		return "", err
+	}
+	documents := []string{}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return "", err
+		}
+		documents = append(documents, string(data))
+	}
+	return strings.Join(documents, "\n---\n"), nil
+}
+
+// renderArgoHelm renders the chart at the sources path with its helm configuration.
+// Values are merged in the same order as Argo CD - value files, values or the values object and then parameters.
+func renderArgoHelm(root string, source ArgoSource, refs map[string]string, app ArgoApplication) (string, error) {
+
+	helm := source.Helm
+	// charts are rendered with their defaults if helm isn't configured
+	if helm == nil {
+		helm = &ArgoHelm{}
+		source.Helm = helm
+	}
+
+	options := HelmOptions{
+		ReleaseName: helm.ReleaseName,
+		Namespace:   app.Spec.Destination.Namespace,
+	}
+	if options.ReleaseName == "" {
+		options.ReleaseName = app.Metadata.Name
+	}
+
+	// value files of charts from a chart repository are relative to the chart
+	if source.Chart != "" && hasRelativeValueFiles(helm) {
+		expanded, err := expandHelmChart(root)
+		if err != nil {
+			return "", err
+		}
+		root = expanded
+	}
+
+	valueFiles, err := resolveValueFiles(root, source, refs)
+	if err != nil {
+		return "", err
+	}
+	options.ValueFiles = valueFiles
+
+	// Argo CD ignores values when a values object is set
+	inline := helm.Values
+	if len(helm.ValuesObject) > 0 {
+		b, err := yaml.Marshal(helm.ValuesObject)
+		if err != nil {
+			return "", fmt.Errorf("error marshalling values object: %w", err)
+		}
+		inline = string(b)
+	}
+	if inline != "" {
 
 		// create temp file with contents
 		f, err := os.CreateTemp("", "values-file")
@@ -137,40 +447,70 @@ func runHelm(root string, app ArgoApplication) (string, error) {
 		}
 		defer os.Remove(f.Name())
 
-		if _, err = f.WriteString(app.Spec.Source.Helm.Values); err != nil {
+		if _, err = f.WriteString(inline); err != nil {
 			return "", fmt.Errorf("error writing to  tempfile: %w", err)
 		}
-		args = append(args, "-f", f.Name())
+		if err := f.Close(); err != nil {
+			return "", fmt.Errorf("error writing to  tempfile: %w", err)
+		}
+		options.ValueFiles = append(options.ValueFiles, f.Name())
 	}
 
-	args = append(args, "foo")
-	args = append(args, app.Spec.Source.Path)
+	for _, p := range helm.Parameters {
+		// in Helm, commas separate values so need to be escaped :shrug
+		v := fmt.Sprintf("%s=%s", p.Name, strings.ReplaceAll(p.Value, ",", "\\,"))
+		if p.ForceString {
+			options.StringValues = append(options.StringValues, v)
+		} else {
+			options.Values = append(options.Values, v)
+		}
+	}
 
-	return exec.GetCommandOutput(root, "helm", args...)
+	chartPath := path.Join(root, source.Path)
+	content, err := renderHelmChart(chartPath, options)
+	if err != nil {
+		return "", fmt.Errorf("error rendering helm chart: %w at %s", err, chartPath)
+	}
+	return content, nil
 }
 
-// clones the githubURL and checkouts the 'tagOrHead' returing the directory path or an error
-func cachedGithubCheckout(directory string, githubURL, tagOrHead string) (string, error) {
-
-	githubFolderName := strings.ReplaceAll(githubURL, "/", "_")
-	folder := fmt.Sprintf("./%s-%s", githubFolderName, tagOrHead)
-	p := path.Join(directory, folder)
-
-	if err := os.MkdirAll(directory, os.ModePerm); err != nil {
-		return "", fmt.Errorf("error creating checkout directory: %w", err)
+// resolveValueFiles returns the paths of the sources value files. Paths are relative to
+// the source path unless they start with a ref to another source e.g. '$values/values.yaml'
+func resolveValueFiles(root string, source ArgoSource, refs map[string]string) ([]string, error) {
+	all := []string{}
+	if source.Helm == nil {
+		return all, nil
 	}
-
-	if stat, err := os.Stat(p); err == nil && stat.IsDir() {
-		return p, nil
+	for _, f := range source.Helm.ValueFiles {
+		if !strings.HasPrefix(f, "$") {
+			all = append(all, filepath.Join(root, source.Path, f))
+			continue
+		}
+		ref, rest, _ := strings.Cut(f, "/")
+		refRoot, exists := refs[ref]
+		if !exists {
+			return nil, fmt.Errorf("error resolving value file %s: unknown ref %s", f, ref)
+		}
+		all = append(all, filepath.Join(refRoot, rest))
 	}
+	return all, nil
+}
 
-	if _, err := exec.GetCommandOutput(directory, "git", "clone", githubURL, folder); err != nil {
-		return "", fmt.Errorf("error running git clone: %w", err)
+// hasRelativeValueFiles returns true if any value file isn't from a ref to another source
+func hasRelativeValueFiles(helm *ArgoHelm) bool {
+	for _, f := range helm.ValueFiles {
+		if !strings.HasPrefix(f, "$") {
+			return true
+		}
 	}
+	return false
+}
 
-	if _, err := exec.GetCommandOutput(p, "git", "checkout", tagOrHead); err != nil {
-		return "", fmt.Errorf("error running git checkout: %w", err)
+// resolveRepoURL returns repoURL if it is absolute otherwise
+// it is assumed to be a repository hosted at githubURL
+func resolveRepoURL(githubURL, repoURL string) string {
+	if strings.Contains(repoURL, "://") || strings.HasPrefix(repoURL, "git@") {
+		return repoURL
 	}
-
-	return p, nil
+	return fmt.Sprintf("%s/%s", strings.TrimSuffix(githubURL, "/"), strings.TrimPrefix(repoURL, "/"))
 }
@@ -0,0 +1,221 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/Masterminds/sprig"
+	"gopkg.in/yaml.v3"
+)
+
+type ArgoApplicationSet struct {
+	Kind     string
+	Metadata struct {
+		Name string
+	}
+	Spec struct {
+		// GoTemplate switches the template syntax from '{{path.basename}}' to '{{ .path.basename }}'
+		GoTemplate bool `yaml:"goTemplate"`
+		Generators []ArgoGenerator
+		Template   yaml.Node
+	}
+}
+
+// ArgoGenerator is one of the supported generators
+type ArgoGenerator struct {
+	List *struct {
+		Elements []map[string]interface{}
+	}
+	Git *struct {
+		RepoURL     string `yaml:"repoURL"`
+		Revision    string
+		Directories []struct {
+			Path    string
+			Exclude bool
+		}
+	}
+}
+
+// fastTemplateParam matches the parameters of a non go template e.g. '{{path.basename}}'
+var fastTemplateParam = regexp.MustCompile(`{{\s*([^{}\s]+)\s*}}`)
+
+// notNormalized matches the characters replaced in a normalized basename
+var notNormalized = regexp.MustCompile(`[^a-zA-Z0-9-]`)
+
+// expand returns an application for each of the parameters produced by the generators
+func (w *argoWalk) expand(ctx context.Context, set ArgoApplicationSet) ([]ArgoApplication, error) {
+
+	tmpl, err := yaml.Marshal(&set.Spec.Template)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling template: %w", err)
+	}
+
+	all := []ArgoApplication{}
+	for _, generator := range set.Spec.Generators {
+
+		var params []map[string]interface{}
+
+		switch {
+		case generator.List != nil:
+			params = generator.List.Elements
+		case generator.Git != nil && len(generator.Git.Directories) > 0:
+			params, err = w.gitDirectoryParams(ctx, generator, set.Spec.GoTemplate)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("error expanding generator: only list and git directory generators are supported")
+		}
+
+		for _, p := range params {
+			rendered, err := renderApplicationSetTemplate(string(tmpl), p, set.Spec.GoTemplate)
+			if err != nil {
+				return nil, err
+			}
+			var app ArgoApplication
+			if err := yaml.Unmarshal([]byte(rendered), &app); err != nil {
+				return nil, fmt.Errorf("error unmarshalling rendered template: %w", err)
+			}
+			all = append(all, app)
+		}
+	}
+	return all, nil
+}
+
+// gitDirectoryParams returns the parameters for each directory in the repository matching the generator
+func (w *argoWalk) gitDirectoryParams(ctx context.Context, generator ArgoGenerator, goTemplate bool) ([]map[string]interface{}, error) {
+
+	git := generator.Git
+	revision := git.Revision
+	if revision == "" {
+		revision = "HEAD"
+	}
+	root, err := w.checkout(ctx, git.RepoURL, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := map[string]bool{}
+	for _, d := range git.Directories {
+		if d.Exclude {
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(root, d.Path))
+		if err != nil {
+			return nil, fmt.Errorf("error matching directories %s: %w", d.Path, err)
+		}
+		for _, m := range matches {
+			if stat, err := os.Stat(m); err != nil || !stat.IsDir() {
+				continue
+			}
+			rel, err := filepath.Rel(root, m)
+			if err != nil {
+				return nil, err
+			}
+			matched[filepath.ToSlash(rel)] = true
+		}
+	}
+
+	for _, d := range git.Directories {
+		if !d.Exclude {
+			continue
+		}
+		for m := range matched {
+			if ok, _ := path.Match(d.Path, m); ok {
+				delete(matched, m)
+			}
+		}
+	}
+
+	dirs := []string{}
+	for m := range matched {
+		dirs = append(dirs, m)
+	}
+	sort.Strings(dirs)
+
+	all := []map[string]interface{}{}
+	for _, d := range dirs {
+		all = append(all, gitDirectoryParam(d, goTemplate))
+	}
+	return all, nil
+}
+
+// gitDirectoryParam returns the parameters Argo CD provides for a directory
+func gitDirectoryParam(dir string, goTemplate bool) map[string]interface{} {
+
+	basename := path.Base(dir)
+	normalized := strings.ToLower(notNormalized.ReplaceAllString(basename, "-"))
+	segments := strings.Split(dir, "/")
+
+	if goTemplate {
+		return map[string]interface{}{
+			"path": map[string]interface{}{
+				"path":               dir,
+				"basename":           basename,
+				"basenameNormalized": normalized,
+				"segments":           segments,
+			},
+		}
+	}
+
+	param := map[string]interface{}{
+		"path":                    dir,
+		"path.basename":           basename,
+		"path.basenameNormalized": normalized,
+	}
+	for i, s := range segments {
+		param[fmt.Sprintf("path[%d]", i)] = s
+	}
+	return param
+}
+
+// renderApplicationSetTemplate substitutes the parameters into the template
+func renderApplicationSetTemplate(tmpl string, params map[string]interface{}, goTemplate bool) (string, error) {
+
+	if goTemplate {
+		t, err := template.New("template").Funcs(sprig.TxtFuncMap()).Parse(tmpl)
+		if err != nil {
+			return "", fmt.Errorf("error parsing template: %w", err)
+		}
+		var b bytes.Buffer
+		if err := t.Execute(&b, params); err != nil {
+			return "", fmt.Errorf("error executing template: %w", err)
+		}
+		return b.String(), nil
+	}
+
+	flattened := map[string]string{}
+	flattenParams("", params, flattened)
+
+	return fastTemplateParam.ReplaceAllStringFunc(tmpl, func(s string) string {
+		key := fastTemplateParam.FindStringSubmatch(s)[1]
+		if v, exists := flattened[key]; exists {
+			return v
+		}
+		// unknown parameters are left as is
+		return s
+	}), nil
+}
+
+// flattenParams flattens nested parameters using '.' e.g. 'values.tag'
+func flattenParams(prefix string, params map[string]interface{}, into map[string]string) {
+	for k, v := range params {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, ok := v.(map[string]interface{}); ok {
+			flattenParams(key, nested, into)
+			continue
+		}
+		into[key] = fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,374 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mdevilliers/org-scrounger/pkg/exec"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/provenance"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+func testDeployment(image string) string {
+	return fmt.Sprintf(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: test
+spec:
+  template:
+    spec:
+      containers:
+        - image: %s
+`, image)
+}
+
+// writeTestGitRepo commits the files to a new git repository in dir
+func writeTestGitRepo(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		require.Nil(t, os.MkdirAll(filepath.Dir(p), os.ModePerm))
+		require.Nil(t, os.WriteFile(p, []byte(content), 0600))
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "test"},
+	} {
+		out, err := exec.GetCommandOutput(dir, "git", args...)
+		require.Nil(t, err, out)
+	}
+}
+
+func testArgoOptions(t *testing.T) ArgoOptions {
+	return ArgoOptions{
+		GithubURL:   "https://github.com",
+		CheckoutDir: t.TempDir(),
+		Concurrency: 4,
+	}
+}
+
+func Test_ArgoAppOfApps(t *testing.T) { //nolint:funlen
+
+	repo := "{{repo}}"
+	files := map[string]string{
+		"apps/services.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: services
+spec:
+  generators:
+    - git:
+        repoURL: {{repo}}
+        directories:
+          - path: services/*
+          - path: services/skip
+            exclude: true
+  template:
+    metadata:
+      name: '{{path.basename}}'
+    spec:
+      source:
+        repoURL: {{repo}}
+        path: '{{path}}'
+      destination:
+        namespace: '{{path.basename}}'
+`,
+		"apps/workers.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: ApplicationSet
+metadata:
+  name: workers
+spec:
+  goTemplate: true
+  generators:
+    - list:
+        elements:
+          - name: worker
+            namespace: jobs
+  template:
+    metadata:
+      name: '{{ .name }}'
+    spec:
+      sources:
+        - repoURL: {{repo}}
+          path: 'workers/{{ .name }}'
+        - repoURL: {{repo}}
+          ref: values
+      destination:
+        namespace: '{{ .namespace }}'
+`,
+		"apps/root.yaml": `apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: root
+spec:
+  source:
+    repoURL: {{repo}}
+    path: apps
+`,
+		"services/web/deployment.yaml":     testDeployment("example/web:1.0.0"),
+		"services/api/deployment.yaml":     testDeployment("example/api:1.0.0"),
+		"services/api/kustomization.yaml":  "resources:\n  - deployment.yaml\nimages:\n  - name: example/api\n    newTag: 2.0.0\n",
+		"services/skip/deployment.yaml":    testDeployment("example/skip:1.0.0"),
+		"workers/worker/deployment.yaml":   testDeployment("example/worker:3.0.0"),
+		"workers/worker/nested/other.yaml": testDeployment("example/nested:1.0.0"),
+	}
+
+	dir := t.TempDir()
+	url := "file://" + dir
+	for name, content := range files {
+		files[name] = strings.ReplaceAll(content, repo, url)
+	}
+	writeTestGitRepo(t, dir, files)
+
+	root := filepath.Join(t.TempDir(), "root.yaml")
+	require.Nil(t, os.WriteFile(root, []byte(files["apps/root.yaml"]), 0600))
+
+	images, err := NewArgo(testArgoOptions(t), root).Images(context.Background())
+	require.Nil(t, err)
+
+	actual := map[string]string{}
+	for _, i := range images {
+		actual[i.Name+":"+i.Version] = i.Destination.Namespace
+	}
+	require.Equal(t, map[string]string{
+		"example/web:1.0.0":    "web",
+		"example/api:2.0.0":    "api",
+		"example/worker:3.0.0": "jobs",
+	}, actual)
+}
+
+func Test_ArgoDeleteCacheOnExitKeepsSharedCheckouts(t *testing.T) {
+
+	dir := t.TempDir()
+	writeTestGitRepo(t, dir, map[string]string{"app/deployment.yaml": testDeployment("example/web:1.0.0")})
+
+	root := filepath.Join(t.TempDir(), "root.yaml")
+	require.Nil(t, os.WriteFile(root, []byte(fmt.Sprintf(`apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web
+spec:
+  source:
+    repoURL: file://%s
+    path: app
+`, dir)), 0600))
+
+	options := testArgoOptions(t)
+	options.DeleteCacheOnExit = true
+	// a checkout belonging to another run
+	other := filepath.Join(options.CheckoutDir, "other")
+	require.Nil(t, os.MkdirAll(other, os.ModePerm))
+
+	images, err := NewArgo(options, root).Images(context.Background())
+	require.Nil(t, err)
+	require.Len(t, images, 1)
+
+	require.DirExists(t, other)
+	entries, err := os.ReadDir(options.CheckoutDir)
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+}
+
+func Test_ArgoApplicationsWithTheSameName(t *testing.T) {
+
+	dir := t.TempDir()
+	writeTestGitRepo(t, dir, map[string]string{
+		"dev/deployment.yaml":     testDeployment("example/web:1.0.0"),
+		"prod/deployment.yaml":    testDeployment("example/web:2.0.0"),
+		"staging/deployment.yaml": testDeployment("example/web:3.0.0"),
+	})
+
+	app := func(namespace, path string) string {
+		return fmt.Sprintf(`apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: app
+  namespace: %s
+spec:
+  source:
+    repoURL: file://%s
+    path: %s
+  destination:
+    namespace: %s
+`, namespace, dir, path, path)
+	}
+
+	roots := t.TempDir()
+	dev := filepath.Join(roots, "dev.yaml")
+	require.Nil(t, os.WriteFile(dev, []byte(app("argocd", "dev")), 0600))
+	// the same name in another namespace of the same root
+	prod := filepath.Join(roots, "prod.yaml")
+	require.Nil(t, os.WriteFile(prod, []byte(app("argocd", "prod")+"---\n"+app("other", "staging")), 0600))
+
+	images, err := NewArgo(testArgoOptions(t), dev, prod).Images(context.Background())
+	require.Nil(t, err)
+
+	actual := map[string]string{}
+	for _, i := range images {
+		actual[i.Version] = i.Destination.Namespace
+	}
+	require.Equal(t, map[string]string{"1.0.0": "dev", "2.0.0": "prod", "3.0.0": "staging"}, actual)
+}
+
+func Test_ResolveValueFiles(t *testing.T) {
+
+	source := ArgoSource{
+		Path: "charts/web",
+		Helm: &ArgoHelm{
+			ValueFiles: []string{"values.yaml", "$values/envs/prod.yaml"},
+		},
+	}
+
+	files, err := resolveValueFiles("/checkout/charts", source, map[string]string{"$values": "/checkout/values"})
+	require.Nil(t, err)
+	require.Equal(t, []string{"/checkout/charts/charts/web/values.yaml", "/checkout/values/envs/prod.yaml"}, files)
+
+	_, err = resolveValueFiles("/checkout/charts", source, map[string]string{})
+	require.NotNil(t, err)
+}
+
+func Test_RenderArgoHelm(t *testing.T) {
+
+	chart := writeTestChart(t)
+
+	valuesDir := t.TempDir()
+	require.Nil(t, os.WriteFile(filepath.Join(valuesDir, "prod.yaml"), []byte("sidecar:\n  enabled: true\nimage:\n  tag: 2.0.0\n"), 0600))
+
+	var source ArgoSource
+	require.Nil(t, yaml.Unmarshal([]byte(`
+path: web
+helm:
+  releaseName: custom
+  valueFiles:
+    - $values/prod.yaml
+  values: |
+    image:
+      tag: 3.0.0
+  valuesObject:
+    image:
+      tag: 4.0.0
+  parameters:
+    - name: replicas
+      value: "5"
+`), &source))
+
+	var app ArgoApplication
+	app.Metadata.Name = "web"
+	app.Spec.Destination.Namespace = "prod"
+
+	content, err := renderArgoHelm(filepath.Dir(chart), source, map[string]string{"$values": valuesDir}, app)
+	require.Nil(t, err)
+	require.Contains(t, content, "name: custom-web")
+	require.Contains(t, content, "namespace: prod")
+	require.Contains(t, content, "replicas: 5")
+	require.Contains(t, content, `image: "example/web:4.0.0"`)
+	require.Contains(t, content, `image: "sidecar:1.0.0"`)
+}
+
+func Test_RenderArgoHelmIgnoresValuesWithAValuesObject(t *testing.T) {
+
+	chart := writeTestChart(t)
+
+	var source ArgoSource
+	require.Nil(t, yaml.Unmarshal([]byte(`
+path: web
+helm:
+  values: |
+    sidecar:
+      enabled: true
+  valuesObject:
+    image:
+      tag: 4.0.0
+`), &source))
+
+	var app ArgoApplication
+	app.Metadata.Name = "web"
+	app.Spec.Destination.Namespace = "prod"
+
+	content, err := renderArgoHelm(filepath.Dir(chart), source, map[string]string{}, app)
+	require.Nil(t, err)
+	require.Contains(t, content, `image: "example/web:4.0.0"`)
+	require.NotContains(t, content, `image: "sidecar:1.0.0"`)
+}
+
+func Test_ArgoHelmChartRepository(t *testing.T) {
+
+	t.Setenv("HELM_CACHE_HOME", t.TempDir())
+	t.Setenv("HELM_CONFIG_HOME", t.TempDir())
+	t.Setenv("HELM_DATA_HOME", t.TempDir())
+
+	// serve the packaged test chart from a chart repository
+	chart := writeTestChart(t)
+	require.Nil(t, os.WriteFile(filepath.Join(chart, "values-prod.yaml"), []byte("image:\n  tag: 7.7.7\n"), 0600))
+	chrt, err := loader.Load(chart)
+	require.Nil(t, err)
+
+	repoDir := t.TempDir()
+	packaged, err := chartutil.Save(chrt, repoDir)
+	require.Nil(t, err)
+
+	server := httptest.NewServer(http.FileServer(http.Dir(repoDir)))
+	defer server.Close()
+
+	digest, err := provenance.DigestFile(packaged)
+	require.Nil(t, err)
+	index := repo.NewIndexFile()
+	require.Nil(t, index.MustAdd(chrt.Metadata, filepath.Base(packaged), server.URL, digest))
+	require.Nil(t, index.WriteFile(filepath.Join(repoDir, "index.yaml"), 0600))
+
+	testCases := []struct {
+		desc     string
+		helm     string
+		expected string
+	}{
+		{
+			desc:     "parameters",
+			helm:     "parameters:\n        - name: image.tag\n          value: 9.9.9",
+			expected: "9.9.9",
+		},
+		{
+			desc:     "value files in the chart",
+			helm:     "valueFiles:\n        - values-prod.yaml",
+			expected: "7.7.7",
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+
+			app := filepath.Join(t.TempDir(), "app.yaml")
+			require.Nil(t, os.WriteFile(app, []byte(fmt.Sprintf(`apiVersion: argoproj.io/v1alpha1
+kind: Application
+metadata:
+  name: web
+spec:
+  source:
+    repoURL: %s
+    chart: web
+    targetRevision: 0.1.0
+    helm:
+      %s
+  destination:
+    namespace: prod
+`, server.URL, tC.helm)), 0600))
+
+			images, err := NewArgo(testArgoOptions(t), app).Images(context.Background())
+			require.Nil(t, err)
+			require.Len(t, images, 1)
+			require.Equal(t, "example/web", images[0].Name)
+			require.Equal(t, tC.expected, images[0].Version)
+			require.Equal(t, "prod", images[0].Destination.Namespace)
+		})
+	}
+}
@@ -0,0 +1,317 @@
+package images
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Masterminds/semver"
+	"github.com/mdevilliers/org-scrounger/pkg/exec"
+)
+
+const (
+	// staleLockAge is the age a lock file without an owner is assumed to be left behind by a crashed run
+	staleLockAge = 10 * time.Minute
+	// lockPollInterval is how often a held lock file is checked
+	lockPollInterval = 250 * time.Millisecond
+	// defaultLockTimeout is how long to wait for another run to unlock the checkout directory
+	defaultLockTimeout = 10 * time.Minute
+)
+
+// commitSHA matches a full git commit SHA
+var commitSHA = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// checkoutCache manages shallow git checkouts that are shared between
+// goroutines and concurrent runs of scrng. A run locks the directory from
+// its first checkout until close so checkouts aren't changed while being read,
+// concurrent runs sharing the directory take turns.
+type checkoutCache struct {
+	dir string
+	// sparse only checks out the paths requested
+	sparse bool
+	// lockTimeout is how long to wait for another run to unlock the directory
+	lockTimeout time.Duration
+
+	// mu guards locks, refreshed and release
+	mu sync.Mutex
+	// locks serialise access to a checkout within the process
+	locks map[string]*sync.Mutex
+	// refreshed are the checkouts of moving revisions fetched during this run
+	refreshed map[string]bool
+	// release unlocks the directory for other runs
+	release func()
+}
+
+// DefaultCheckoutDir returns the users cache directory for git checkouts
+func DefaultCheckoutDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("error finding user cache directory: %w", err)
+	}
+	return filepath.Join(dir, "scrng", "checkouts"), nil
+}
+
+func newCheckoutCache(dir string, sparse bool) *checkoutCache {
+	return &checkoutCache{
+		dir:         dir,
+		sparse:      sparse,
+		lockTimeout: defaultLockTimeout,
+		locks:       map[string]*sync.Mutex{},
+		refreshed:   map[string]bool{},
+	}
+}
+
+// checkout returns the directory containing repoURL at revision. If the cache is sparse
+// only the paths are checked out, all paths are checked out if none are specified.
+// Checkouts of revisions that can move e.g. branches are fetched once per run.
+func (c *checkoutCache) checkout(ctx context.Context, repoURL, revision string, paths ...string) (string, error) {
+
+	key := checkoutKey(repoURL, revision)
+	p := filepath.Join(c.dir, key)
+
+	if err := c.lockDir(ctx); err != nil {
+		return "", err
+	}
+
+	unlock := c.lock(key)
+	defer unlock()
+
+	if _, err := os.Stat(filepath.Join(p, ".git")); err != nil {
+		if err := c.clone(p, repoURL, revision, paths); err != nil {
+			// don't leave a partial checkout behind
+			os.RemoveAll(p)
+			return "", err
+		}
+		c.setRefreshed(key)
+		return p, nil
+	}
+
+	if !c.isRefreshed(key) && !isImmutableRevision(revision) {
+		if err := fetchRevision(p, revision); err != nil {
+			return "", err
+		}
+	}
+	c.setRefreshed(key)
+
+	if err := c.widenSparseCheckout(p, paths); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// clone creates a shallow checkout of revision. A fetch is used rather than
+// a clone so that any revision, not just branches and tags, can be checked out.
+func (c *checkoutCache) clone(p, repoURL, revision string, paths []string) error {
+
+	if err := os.MkdirAll(p, os.ModePerm); err != nil {
+		return fmt.Errorf("error creating checkout directory: %w", err)
+	}
+	if _, err := exec.GetCommandOutput(p, "git", "init", "-q"); err != nil {
+		return fmt.Errorf("error running git init: %w", err)
+	}
+	if _, err := exec.GetCommandOutput(p, "git", "remote", "add", "origin", repoURL); err != nil {
+		return fmt.Errorf("error running git remote add: %w", err)
+	}
+	if c.sparse && len(sparsePaths(paths)) > 0 {
+		args := append([]string{"sparse-checkout", "set"}, sparsePaths(paths)...)
+		if out, err := exec.GetCommandOutput(p, "git", args...); err != nil {
+			return fmt.Errorf("error running git sparse-checkout: %s: %w", out, err)
+		}
+	}
+	return fetchRevision(p, revision)
+}
+
+// widenSparseCheckout widens a sparse checkout to include the paths or
+// disables it if all paths are needed
+func (c *checkoutCache) widenSparseCheckout(p string, paths []string) error {
+
+	enabled, _ := exec.GetCommandOutput(p, "git", "config", "--get", "core.sparseCheckout")
+	if enabled != "true" {
+		return nil
+	}
+
+	args := []string{"sparse-checkout", "disable"}
+	if c.sparse && len(sparsePaths(paths)) > 0 {
+		args = append([]string{"sparse-checkout", "add"}, sparsePaths(paths)...)
+	}
+	if out, err := exec.GetCommandOutput(p, "git", args...); err != nil {
+		return fmt.Errorf("error running git sparse-checkout: %s: %w", out, err)
+	}
+	return nil
+}
+
+// lockDir locks the checkout directory for the rest of the run, waiting up to
+// the lock timeout for any other run
+func (c *checkoutCache) lockDir(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.release != nil {
+		return nil
+	}
+	if err := os.MkdirAll(c.dir, os.ModePerm); err != nil {
+		return fmt.Errorf("error creating checkout directory: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.lockTimeout)
+	defer cancel()
+
+	release, err := acquireLockFile(ctx, filepath.Join(c.dir, ".lock"), staleLockAge)
+	if err != nil {
+		return err
+	}
+	c.release = release
+	return nil
+}
+
+// close unlocks the checkout directory, checkouts mustn't be read after close
+func (c *checkoutCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.release != nil {
+		c.release()
+		c.release = nil
+	}
+}
+
+func (c *checkoutCache) isRefreshed(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.refreshed[key]
+}
+
+func (c *checkoutCache) setRefreshed(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.refreshed[key] = true
+}
+
+func (c *checkoutCache) lock(key string) func() {
+	c.mu.Lock()
+	m, exists := c.locks[key]
+	if !exists {
+		m = &sync.Mutex{}
+		c.locks[key] = m
+	}
+	c.mu.Unlock()
+
+	m.Lock()
+	return m.Unlock
+}
+
+// fetchRevision fetches the tip of revision and checks it out
+func fetchRevision(p, revision string) error {
+	if out, err := exec.GetCommandOutput(p, "git", "fetch", "-q", "--depth", "1", "origin", revision); err != nil {
+		return fmt.Errorf("error running git fetch %s: %s: %w", revision, out, err)
+	}
+	if out, err := exec.GetCommandOutput(p, "git", "checkout", "-q", "--force", "FETCH_HEAD"); err != nil {
+		return fmt.Errorf("error running git checkout %s: %s: %w", revision, out, err)
+	}
+	return nil
+}
+
+// sparsePaths returns the paths to check out or none if the root is requested
+func sparsePaths(paths []string) []string {
+	all := []string{}
+	for _, p := range paths {
+		p = strings.Trim(filepath.ToSlash(filepath.Clean(p)), "/")
+		if p == "" || p == "." {
+			return nil
+		}
+		all = append(all, p)
+	}
+	return all
+}
+
+// isImmutableRevision returns true for commit SHAs and semver tags
+func isImmutableRevision(revision string) bool {
+	if commitSHA.MatchString(revision) {
+		return true
+	}
+	// require major.minor.patch as the parsing is lenient
+	if strings.Count(revision, ".") < 2 { //nolint: gomnd
+		return false
+	}
+	_, err := semver.NewVersion(revision)
+	return err == nil
+}
+
+// checkoutKey returns a readable, unique directory name for the revision of the repository
+func checkoutKey(repoURL, revision string) string {
+	h := sha256.Sum256([]byte(repoURL + "@" + revision))
+	name := strings.NewReplacer("://", "_", "/", "_", ":", "_", "@", "_").Replace(repoURL + "-" + revision)
+	return fmt.Sprintf("%s-%s", name, hex.EncodeToString(h[:4]))
+}
+
+// acquireLockFile creates the lock file waiting for any other run holding it until the context is done.
+// Lock files owned by a process that has exited are assumed to be left behind by a crashed run.
+func acquireLockFile(ctx context.Context, path string, staleAfter time.Duration) (func(), error) {
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			fmt.Fprintf(f, "%d", os.Getpid())
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("error creating lock file %s: %w", path, err)
+		}
+		if isStaleLockFile(path, staleAfter) {
+			if err := removeStaleLockFile(path, staleAfter); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("error waiting for lock file %s held by another run: %w", path, ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// removeStaleLockFile removes the lock file if it is still stale. The lock file is moved aside
+// and checked again so a lock file created by another run since it was found to be stale isn't removed.
+func removeStaleLockFile(path string, staleAfter time.Duration) error {
+	aside := fmt.Sprintf("%s.%d.stale", path, os.Getpid())
+	if err := os.Rename(path, aside); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// another run removed it first
+			return nil
+		}
+		return fmt.Errorf("error removing stale lock file %s: %w", path, err)
+	}
+	defer os.Remove(aside)
+
+	if isStaleLockFile(aside, staleAfter) {
+		return nil
+	}
+	// another runs lock was moved, put it back unless yet another run has locked the directory
+	if err := os.Link(aside, path); err != nil {
+		return fmt.Errorf("error restoring lock file %s: %w", path, err)
+	}
+	return nil
+}
+
+// isStaleLockFile returns true if the process owning the lock file has exited. Lock files
+// without an owner, e.g. the run crashed before writing it, are stale once older than staleAfter.
+func isStaleLockFile(path string, staleAfter time.Duration) bool {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	if pid, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil {
+		return !isProcessAlive(pid)
+	}
+	stat, err := os.Stat(path)
+	return err == nil && time.Since(stat.ModTime()) > staleAfter
+}
@@ -0,0 +1,288 @@
+package images
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mdevilliers/org-scrounger/pkg/exec"
+	"github.com/stretchr/testify/require"
+)
+
+func commitTestFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	require.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	for _, args := range [][]string{
+		{"add", "-A"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "update"},
+	} {
+		out, err := exec.GetCommandOutput(dir, "git", args...)
+		require.Nil(t, err, out)
+	}
+}
+
+func readTestFile(t *testing.T, path ...string) string {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(path...))
+	require.Nil(t, err)
+	return string(b)
+}
+
+func Test_CheckoutRefreshesMovingRevisionsOncePerRun(t *testing.T) {
+
+	origin := t.TempDir()
+	writeTestGitRepo(t, origin, map[string]string{"version": "1"})
+	url := "file://" + origin
+	dir := t.TempDir()
+
+	run := newCheckoutCache(dir, false)
+	p, err := run.checkout(context.Background(), url, "HEAD")
+	require.Nil(t, err)
+	require.Equal(t, "1", readTestFile(t, p, "version"))
+
+	commitTestFile(t, origin, "version", "2")
+
+	// the same run reuses the checkout
+	p, err = run.checkout(context.Background(), url, "HEAD")
+	require.Nil(t, err)
+	require.Equal(t, "1", readTestFile(t, p, "version"))
+
+	run.close()
+
+	// the next run fetches the moved revision
+	nextRun := newCheckoutCache(dir, false)
+	defer nextRun.close()
+	next, err := nextRun.checkout(context.Background(), url, "HEAD")
+	require.Nil(t, err)
+	require.Equal(t, p, next)
+	require.Equal(t, "2", readTestFile(t, p, "version"))
+}
+
+func Test_CheckoutSparse(t *testing.T) {
+
+	origin := t.TempDir()
+	writeTestGitRepo(t, origin, map[string]string{"a/file": "a", "b/file": "b"})
+	url := "file://" + origin
+
+	cache := newCheckoutCache(t.TempDir(), true)
+	defer cache.close()
+
+	p, err := cache.checkout(context.Background(), url, "HEAD", "a")
+	require.Nil(t, err)
+	require.FileExists(t, filepath.Join(p, "a", "file"))
+	require.NoFileExists(t, filepath.Join(p, "b", "file"))
+
+	_, err = cache.checkout(context.Background(), url, "HEAD", "b")
+	require.Nil(t, err)
+	require.FileExists(t, filepath.Join(p, "a", "file"))
+	require.FileExists(t, filepath.Join(p, "b", "file"))
+}
+
+func Test_CheckoutConcurrently(t *testing.T) {
+
+	origin := t.TempDir()
+	writeTestGitRepo(t, origin, map[string]string{"version": "1"})
+	url := "file://" + origin
+	dir := t.TempDir()
+
+	// separate caches behave like separate runs sharing the directory
+	var wg sync.WaitGroup
+	paths := make([]string, 8)
+	errs := make([]error, 8)
+	for i := range paths {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			run := newCheckoutCache(dir, false)
+			defer run.close()
+			paths[i], errs[i] = run.checkout(context.Background(), url, "HEAD")
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range paths {
+		require.Nil(t, errs[i])
+		require.Equal(t, paths[0], paths[i])
+	}
+	require.Equal(t, "1", readTestFile(t, paths[0], "version"))
+	require.NoFileExists(t, filepath.Join(dir, ".lock"))
+}
+
+func Test_CheckoutSharedCacheConcurrently(t *testing.T) {
+
+	urls := []string{}
+	for i := 0; i < 4; i++ {
+		origin := t.TempDir()
+		writeTestGitRepo(t, origin, map[string]string{"version": fmt.Sprint(i)})
+		urls = append(urls, "file://"+origin)
+	}
+
+	// one run checking out several repos in parallel
+	cache := newCheckoutCache(t.TempDir(), false)
+	defer cache.close()
+
+	var wg sync.WaitGroup
+	paths := make([]string, len(urls)*2)
+	errs := make([]error, len(urls)*2)
+	for i := range paths {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			paths[i], errs[i] = cache.checkout(context.Background(), urls[i%len(urls)], "HEAD")
+		}(i)
+	}
+	wg.Wait()
+
+	for i := range paths {
+		require.Nil(t, errs[i])
+		require.Equal(t, fmt.Sprint(i%len(urls)), readTestFile(t, paths[i], "version"))
+	}
+}
+
+func Test_CheckoutDirectoryIsLockedUntilClosed(t *testing.T) {
+
+	origin := t.TempDir()
+	writeTestGitRepo(t, origin, map[string]string{"version": "1"})
+	url := "file://" + origin
+	dir := t.TempDir()
+
+	run := newCheckoutCache(dir, false)
+	_, err := run.checkout(context.Background(), url, "HEAD")
+	require.Nil(t, err)
+
+	done := make(chan error)
+	go func() {
+		next := newCheckoutCache(dir, false)
+		defer next.close()
+		_, err := next.checkout(context.Background(), url, "HEAD")
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		require.Fail(t, "checkout directory wasn't locked")
+	case <-time.After(2 * lockPollInterval):
+	}
+
+	run.close()
+	require.Nil(t, <-done)
+}
+
+func Test_CheckoutDirectoryLockTimesOut(t *testing.T) {
+
+	origin := t.TempDir()
+	writeTestGitRepo(t, origin, map[string]string{"version": "1"})
+	url := "file://" + origin
+	dir := t.TempDir()
+
+	run := newCheckoutCache(dir, false)
+	defer run.close()
+	_, err := run.checkout(context.Background(), url, "HEAD")
+	require.Nil(t, err)
+
+	next := newCheckoutCache(dir, false)
+	next.lockTimeout = 2 * lockPollInterval
+	defer next.close()
+
+	_, err = next.checkout(context.Background(), url, "HEAD")
+	require.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	// cancelling the context stops waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = newCheckoutCache(dir, false).checkout(ctx, url, "HEAD")
+	require.True(t, errors.Is(err, context.Canceled))
+}
+
+func Test_StaleLockFiles(t *testing.T) {
+
+	exited := osexec.Command("git", "--version")
+	require.Nil(t, exited.Run())
+
+	old := time.Now().Add(-time.Hour)
+
+	testCases := []struct {
+		desc     string
+		content  string
+		modified time.Time
+		expected bool
+	}{
+		{desc: "owner has exited", content: fmt.Sprint(exited.Process.Pid), modified: time.Now(), expected: true},
+		{desc: "owner is running", content: fmt.Sprint(os.Getpid()), modified: old, expected: false},
+		{desc: "no owner", content: "", modified: time.Now(), expected: false},
+		{desc: "no owner and old", content: "", modified: old, expected: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			lock := filepath.Join(t.TempDir(), "checkout.lock")
+			require.Nil(t, os.WriteFile(lock, []byte(tC.content), 0600))
+			require.Nil(t, os.Chtimes(lock, tC.modified, tC.modified))
+			require.Equal(t, tC.expected, isStaleLockFile(lock, time.Minute))
+		})
+	}
+}
+
+func Test_StaleLockFilesAreRemoved(t *testing.T) {
+
+	exited := osexec.Command("git", "--version")
+	require.Nil(t, exited.Run())
+
+	lock := filepath.Join(t.TempDir(), "checkout.lock")
+	require.Nil(t, os.WriteFile(lock, []byte(fmt.Sprint(exited.Process.Pid)), 0600))
+
+	release, err := acquireLockFile(context.Background(), lock, time.Minute)
+	require.Nil(t, err)
+	require.Equal(t, fmt.Sprint(os.Getpid()), readTestFile(t, lock))
+	release()
+	require.NoFileExists(t, lock)
+}
+
+func Test_IsImmutableRevision(t *testing.T) {
+	testCases := []struct {
+		revision string
+		expected bool
+	}{
+		{revision: "HEAD", expected: false},
+		{revision: "main", expected: false},
+		{revision: "1", expected: false},
+		{revision: "v1.2", expected: false},
+		{revision: "v1.2.3", expected: true},
+		{revision: "1.2.3-rc.1", expected: true},
+		{revision: "0123456789abcdef0123456789abcdef01234567", expected: true},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.revision, func(t *testing.T) {
+			require.Equal(t, tC.expected, isImmutableRevision(tC.revision))
+		})
+	}
+}
+
+func Test_StaleLockFileTakenByAnotherRunIsKept(t *testing.T) {
+
+	exited := osexec.Command("git", "--version")
+	require.Nil(t, exited.Run())
+
+	// another run has removed the stale lock file and locked the directory
+	// since the lock file was found to be stale
+	lock := filepath.Join(t.TempDir(), "checkout.lock")
+	require.Nil(t, os.WriteFile(lock, []byte(fmt.Sprint(os.Getpid())), 0600))
+
+	require.Nil(t, removeStaleLockFile(lock, time.Minute))
+	require.Equal(t, fmt.Sprint(os.Getpid()), readTestFile(t, lock))
+
+	entries, err := os.ReadDir(filepath.Dir(lock))
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+
+	// the stale lock file is removed
+	require.Nil(t, os.WriteFile(lock, []byte(fmt.Sprint(exited.Process.Pid)), 0600))
+	require.Nil(t, removeStaleLockFile(lock, time.Minute))
+	require.NoFileExists(t, lock)
+}
@@ -0,0 +1,208 @@
+package images
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mdevilliers/org-scrounger/pkg/mapping"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+type clusterProvider struct {
+	client  kubernetes.Interface
+	options ClusterOptions
+}
+
+// ClusterOptions select the workloads listed in the cluster
+type ClusterOptions struct {
+	// Namespaces to list, all namespaces are listed if empty
+	Namespaces []string
+	// NamespaceSelector is a label selector used to find namespaces e.g. 'team=payments'
+	NamespaceSelector string
+	// IncludePods lists pods that aren't managed by a controller
+	IncludePods bool
+}
+
+// NewKubernetesClient returns a client for the context in the kubeconfig. The default
+// loading rules are used if kubeconfig is empty and the current context if kubeContext is empty.
+func NewKubernetesClient(kubeconfig, kubeContext string) (kubernetes.Interface, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("error loading kubeconfig: %w", err)
+	}
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("error creating kubernetes client: %w", err)
+	}
+	return client, nil
+}
+
+// NewCluster returns a provider listing the images of the workloads running in a cluster
+func NewCluster(client kubernetes.Interface, options ClusterOptions) *clusterProvider {
+	return &clusterProvider{
+		client:  client,
+		options: options,
+	}
+}
+
+// workload is the subset of a workload needed to extract its images
+type workload struct {
+	kind      string
+	name      string
+	namespace string
+	replicas  int
+	pod       corev1.PodSpec
+}
+
+func (c *clusterProvider) Images(ctx context.Context) ([]mapping.Image, error) {
+
+	namespaces, err := c.namespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	all := []workload{}
+	for _, namespace := range namespaces {
+		workloads, err := c.workloads(ctx, namespace)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, workloads...)
+	}
+	return workloadImages(all), nil
+}
+
+// namespaces returns the namespaces to list or metav1.NamespaceAll
+func (c *clusterProvider) namespaces(ctx context.Context) ([]string, error) {
+	if c.options.NamespaceSelector == "" {
+		if len(c.options.Namespaces) == 0 {
+			return []string{metav1.NamespaceAll}, nil
+		}
+		return c.options.Namespaces, nil
+	}
+
+	list, err := c.client.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: c.options.NamespaceSelector})
+	if err != nil {
+		return nil, fmt.Errorf("error listing namespaces: %w", err)
+	}
+	selected := map[string]bool{}
+	for _, n := range c.options.Namespaces {
+		selected[n] = true
+	}
+	all := []string{}
+	for _, n := range list.Items {
+		// both the names and selector need to match if specified
+		if len(selected) > 0 && !selected[n.Name] {
+			continue
+		}
+		all = append(all, n.Name)
+	}
+	return all, nil
+}
+
+func (c *clusterProvider) workloads(ctx context.Context, namespace string) ([]workload, error) { //nolint:funlen
+
+	all := []workload{}
+	opts := metav1.ListOptions{}
+
+	deployments, err := c.client.AppsV1().Deployments(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing deployments: %w", err)
+	}
+	for _, d := range deployments.Items {
+		all = append(all, workload{kind: "Deployment", name: d.Name, namespace: d.Namespace, replicas: int(d.Status.Replicas), pod: d.Spec.Template.Spec})
+	}
+
+	statefulSets, err := c.client.AppsV1().StatefulSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing statefulsets: %w", err)
+	}
+	for _, s := range statefulSets.Items {
+		all = append(all, workload{kind: "StatefulSet", name: s.Name, namespace: s.Namespace, replicas: int(s.Status.Replicas), pod: s.Spec.Template.Spec})
+	}
+
+	daemonSets, err := c.client.AppsV1().DaemonSets(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing daemonsets: %w", err)
+	}
+	for _, d := range daemonSets.Items {
+		all = append(all, workload{kind: "DaemonSet", name: d.Name, namespace: d.Namespace, replicas: int(d.Status.CurrentNumberScheduled), pod: d.Spec.Template.Spec})
+	}
+
+	cronJobs, err := c.client.BatchV1().CronJobs(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing cronjobs: %w", err)
+	}
+	for _, j := range cronJobs.Items {
+		all = append(all, workload{kind: "CronJob", name: j.Name, namespace: j.Namespace, replicas: len(j.Status.Active), pod: j.Spec.JobTemplate.Spec.Template.Spec})
+	}
+
+	jobs, err := c.client.BatchV1().Jobs(namespace).List(ctx, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing jobs: %w", err)
+	}
+	for _, j := range jobs.Items {
+		// jobs created by a cronjob are counted against the cronjob
+		if owner := metav1.GetControllerOf(&j); owner != nil && owner.Kind == "CronJob" {
+			continue
+		}
+		all = append(all, workload{kind: "Job", name: j.Name, namespace: j.Namespace, replicas: int(j.Status.Active), pod: j.Spec.Template.Spec})
+	}
+
+	if c.options.IncludePods {
+		pods, err := c.client.CoreV1().Pods(namespace).List(ctx, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error listing pods: %w", err)
+		}
+		for _, p := range pods.Items {
+			// pods managed by a controller are counted against the controller
+			if metav1.GetControllerOf(&p) != nil {
+				continue
+			}
+			all = append(all, workload{kind: "Pod", name: p.Name, namespace: p.Namespace, replicas: 1, pod: p.Spec})
+		}
+	}
+
+	return all, nil
+}
+
+// workloadImages aggregates the images of the workloads summing the replicas
+// of the same image in the same namespace
+func workloadImages(workloads []workload) []mapping.Image {
+
+	all := imageSet{}
+
+	for _, w := range workloads {
+		add := func(container, image, containerType string) {
+			all.add(image, w.namespace, mapping.Workload{
+				Kind:          w.kind,
+				Name:          w.name,
+				Container:     container,
+				ContainerType: containerType,
+				Replicas:      w.replicas,
+			})
+		}
+		for _, c := range w.pod.InitContainers {
+			containerType := mapping.ContainerTypeInit
+			// init containers that keep running are native sidecars
+			if c.RestartPolicy != nil && *c.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+				containerType = mapping.ContainerTypeSidecar
+			}
+			add(c.Name, c.Image, containerType)
+		}
+		for _, c := range w.pod.Containers {
+			add(c.Name, c.Image, mapping.ContainerTypeMain)
+		}
+		for _, c := range w.pod.EphemeralContainers {
+			add(c.Name, c.Image, mapping.ContainerTypeEphemeral)
+		}
+	}
+	return all.images()
+}
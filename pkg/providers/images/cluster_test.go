@@ -0,0 +1,159 @@
+package images
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mdevilliers/org-scrounger/pkg/mapping"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func podSpec(images ...string) corev1.PodTemplateSpec {
+	spec := corev1.PodTemplateSpec{}
+	for _, i := range images {
+		spec.Spec.Containers = append(spec.Spec.Containers, corev1.Container{Image: i})
+	}
+	return spec
+}
+
+func testClusterObjects() []runtime.Object {
+	controller := true
+	return []runtime.Object{
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "prod", Labels: map[string]string{"team": "payments"}}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "dev"}},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "prod"},
+			Spec:       appsv1.DeploymentSpec{Template: podSpec("example/web:1.0.0", "example/proxy:1.0.0")},
+			Status:     appsv1.DeploymentStatus{Replicas: 3},
+		},
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "prod"},
+			Spec:       appsv1.StatefulSetSpec{Template: podSpec("example/db:1.0.0")},
+			Status:     appsv1.StatefulSetStatus{Replicas: 2},
+		},
+		&appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "agent", Namespace: "prod"},
+			Spec:       appsv1.DaemonSetSpec{Template: podSpec("example/proxy:1.0.0")},
+			Status:     appsv1.DaemonSetStatus{CurrentNumberScheduled: 4},
+		},
+		&batchv1.CronJob{
+			ObjectMeta: metav1.ObjectMeta{Name: "report", Namespace: "prod"},
+			Spec:       batchv1.CronJobSpec{JobTemplate: batchv1.JobTemplateSpec{Spec: batchv1.JobSpec{Template: podSpec("example/report:1.0.0")}}},
+			Status:     batchv1.CronJobStatus{Active: []corev1.ObjectReference{{Name: "report-1"}}},
+		},
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "report-1", Namespace: "prod", OwnerReferences: []metav1.OwnerReference{{Kind: "CronJob", Name: "report", Controller: &controller}}},
+			Spec:       batchv1.JobSpec{Template: podSpec("example/report:1.0.0")},
+			Status:     batchv1.JobStatus{Active: 1},
+		},
+		&batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{Name: "migrate", Namespace: "dev"},
+			Spec:       batchv1.JobSpec{Template: podSpec("example/migrate:1.0.0")},
+			Status:     batchv1.JobStatus{Active: 1},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "dev"},
+			Spec:       podSpec("example/debug:1.0.0").Spec,
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-abc", Namespace: "prod", OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-123", Controller: &controller}}},
+			Spec:       podSpec("example/web:1.0.0").Spec,
+		},
+	}
+}
+
+func Test_ClusterImages(t *testing.T) {
+
+	testCases := []struct {
+		desc     string
+		options  ClusterOptions
+		expected map[string]int
+	}{
+		{
+			desc:    "all namespaces",
+			options: ClusterOptions{},
+			expected: map[string]int{
+				"example/web_prod":    3,
+				"example/proxy_prod":  7,
+				"example/db_prod":     2,
+				"example/report_prod": 1,
+				"example/migrate_dev": 1,
+			},
+		},
+		{
+			desc:    "namespaces with pods",
+			options: ClusterOptions{Namespaces: []string{"dev"}, IncludePods: true},
+			expected: map[string]int{
+				"example/migrate_dev": 1,
+				"example/debug_dev":   1,
+			},
+		},
+		{
+			desc:    "namespace selector",
+			options: ClusterOptions{NamespaceSelector: "team=payments"},
+			expected: map[string]int{
+				"example/web_prod":    3,
+				"example/proxy_prod":  7,
+				"example/db_prod":     2,
+				"example/report_prod": 1,
+			},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+
+			client := fake.NewSimpleClientset(testClusterObjects()...)
+
+			images, err := NewCluster(client, tC.options).Images(context.Background())
+			require.Nil(t, err)
+			require.Equal(t, tC.expected, countsByNamespace(images))
+		})
+	}
+}
+
+func Test_ClusterWorkloadDetail(t *testing.T) {
+
+	always := corev1.ContainerRestartPolicyAlways
+	client := fake.NewSimpleClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "debug", Namespace: "dev"},
+		Spec: corev1.PodSpec{
+			InitContainers: []corev1.Container{
+				{Name: "init", Image: "example/init:1.0.0"},
+				{Name: "proxy", Image: "example/proxy:1.0.0", RestartPolicy: &always},
+			},
+			Containers: []corev1.Container{{Name: "debug", Image: "example/debug:1.0.0"}},
+			EphemeralContainers: []corev1.EphemeralContainer{
+				{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "shell", Image: "example/shell:1.0.0"}},
+			},
+		},
+	})
+
+	images, err := NewCluster(client, ClusterOptions{IncludePods: true}).Images(context.Background())
+	require.Nil(t, err)
+
+	actual := map[string]mapping.Workload{}
+	for _, i := range images {
+		require.Len(t, i.Workloads, 1)
+		actual[i.Name] = i.Workloads[0]
+	}
+	require.Equal(t, map[string]mapping.Workload{
+		"example/init":  {Kind: "Pod", Name: "debug", Container: "init", ContainerType: mapping.ContainerTypeInit, Replicas: 1},
+		"example/proxy": {Kind: "Pod", Name: "debug", Container: "proxy", ContainerType: mapping.ContainerTypeSidecar, Replicas: 1},
+		"example/debug": {Kind: "Pod", Name: "debug", Container: "debug", ContainerType: mapping.ContainerTypeMain, Replicas: 1},
+		"example/shell": {Kind: "Pod", Name: "debug", Container: "shell", ContainerType: mapping.ContainerTypeEphemeral, Replicas: 1},
+	}, actual)
+}
+
+func countsByNamespace(images []mapping.Image) map[string]int {
+	ret := map[string]int{}
+	for _, i := range images {
+		ret[i.Name+"_"+i.Destination.Namespace] = i.Count
+	}
+	return ret
+}
@@ -0,0 +1,201 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mdevilliers/org-scrounger/pkg/mapping"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/downloader"
+	"helm.sh/helm/v3/pkg/engine"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/repo"
+)
+
+type helmProvider struct {
+	charts  []string
+	options HelmOptions
+}
+
+// HelmOptions are the options used to render a chart
+type HelmOptions struct {
+	ReleaseName string
+	Namespace   string
+	// ValueFiles are merged in order, equivalent to 'helm template -f'
+	ValueFiles []string
+	// Values are equivalent to 'helm template --set'
+	Values []string
+	// StringValues are equivalent to 'helm template --set-string'
+	StringValues []string
+	// ImagePaths are extra JSONPaths to images in CRDs
+	ImagePaths []string
+}
+
+// NewHelm returns a provider rendering the charts, either directories or
+// packaged charts, with the options
+func NewHelm(options HelmOptions, charts ...string) *helmProvider {
+	return &helmProvider{
+		charts:  charts,
+		options: options,
+	}
+}
+
+func (h *helmProvider) Images(ctx context.Context) ([]mapping.Image, error) {
+	all := []mapping.Image{}
+
+	for _, chart := range h.charts {
+		content, err := renderHelmChart(chart, h.options)
+		if err != nil {
+			return nil, fmt.Errorf("error rendering helm chart: %w at %s", err, chart)
+		}
+		images, err := resolveImages(content, h.options.Namespace, h.options.ImagePaths...)
+		if err != nil {
+			return nil, fmt.Errorf("error extracting images: %w", err)
+		}
+
+		all = append(all, images...)
+
+	}
+	return all, nil
+}
+
+// renderHelmChart renders the chart at chartPath returning a
+// big ball of yaml or an error
+func renderHelmChart(chartPath string, options HelmOptions) (string, error) {
+
+	chrt, err := loader.Load(chartPath)
+	if err != nil {
+		return "", fmt.Errorf("error loading chart: %w", err)
+	}
+
+	valueOpts := values.Options{
+		ValueFiles:   options.ValueFiles,
+		Values:       options.Values,
+		StringValues: options.StringValues,
+	}
+	vals, err := valueOpts.MergeValues(getter.Providers{})
+	if err != nil {
+		return "", fmt.Errorf("error merging values: %w", err)
+	}
+
+	if err := chartutil.ProcessDependencies(chrt, vals); err != nil {
+		return "", fmt.Errorf("error processing chart dependencies: %w", err)
+	}
+
+	releaseOptions := chartutil.ReleaseOptions{
+		Name:      options.ReleaseName,
+		Namespace: options.Namespace,
+		Revision:  1,
+		IsInstall: true,
+	}
+	renderValues, err := chartutil.ToRenderValues(chrt, vals, releaseOptions, chartutil.DefaultCapabilities)
+	if err != nil {
+		return "", fmt.Errorf("error building render values: %w", err)
+	}
+
+	rendered, err := engine.Render(chrt, renderValues)
+	if err != nil {
+		return "", fmt.Errorf("error rendering templates: %w", err)
+	}
+
+	names := []string{}
+	for name, content := range rendered {
+		base := path.Base(name)
+		// skip the notes, partials and templates that render to nothing
+		if base == "NOTES.txt" || strings.HasPrefix(base, "_") || strings.TrimSpace(content) == "" {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	documents := []string{}
+	for _, name := range names {
+		documents = append(documents, strings.TrimSpace(rendered[name]))
+	}
+	return strings.Join(documents, "\n---\n"), nil
+}
+
+// pullHelmChart downloads the chart from the helm repository, either a chart repository
+// or an OCI registry, into directory returning the path to the packaged chart or an error.
+// An empty version is the latest version of the chart.
+func pullHelmChart(directory, repoURL, chart, version string) (string, error) {
+
+	name := strings.NewReplacer("://", "_", "/", "_", ":", "_").Replace(fmt.Sprintf("%s_%s-%s", repoURL, chart, version))
+	dest := filepath.Join(directory, name)
+
+	// charts are immutable so reuse any previous download
+	if existing, _ := filepath.Glob(filepath.Join(dest, "*.tgz")); version != "" && len(existing) == 1 {
+		return existing[0], nil
+	}
+	if err := os.MkdirAll(dest, os.ModePerm); err != nil {
+		return "", fmt.Errorf("error creating chart directory: %w", err)
+	}
+
+	settings := cli.New()
+	dl := downloader.ChartDownloader{
+		Out:              io.Discard,
+		Getters:          getter.All(settings),
+		RepositoryConfig: settings.RepositoryConfig,
+		RepositoryCache:  settings.RepositoryCache,
+	}
+
+	// Argo CD references OCI registries without a scheme
+	if !strings.Contains(repoURL, "://") {
+		repoURL = fmt.Sprintf("%s://%s", registry.OCIScheme, repoURL)
+	}
+
+	var ref string
+	if registry.IsOCI(repoURL) {
+		client, err := registry.NewClient()
+		if err != nil {
+			return "", fmt.Errorf("error creating registry client: %w", err)
+		}
+		dl.RegistryClient = client
+		dl.Options = append(dl.Options, getter.WithRegistryClient(client))
+		ref = fmt.Sprintf("%s/%s", strings.TrimSuffix(repoURL, "/"), chart)
+	} else {
+		var err error
+		ref, err = repo.FindChartInRepoURL(repoURL, chart, version, "", "", "", dl.Getters)
+		if err != nil {
+			return "", fmt.Errorf("error finding chart %s in %s: %w", chart, repoURL, err)
+		}
+	}
+
+	p, _, err := dl.DownloadTo(ref, version, dest)
+	if err != nil {
+		return "", fmt.Errorf("error downloading chart %s@%s: %w", ref, version, err)
+	}
+	return p, nil
+}
+
+// expandHelmChart unpacks the chart archive next to it returning the directory of the chart
+func expandHelmChart(archive string) (string, error) {
+
+	dir := strings.TrimSuffix(archive, filepath.Ext(archive))
+	if err := os.RemoveAll(dir); err != nil {
+		return "", fmt.Errorf("error removing expanded chart: %w", err)
+	}
+	if err := chartutil.ExpandFile(dir, archive); err != nil {
+		return "", fmt.Errorf("error expanding chart %s: %w", archive, err)
+	}
+	// the chart is expanded to a directory named after the chart
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("error expanding chart %s: %w", archive, err)
+	}
+	if len(entries) != 1 || !entries[0].IsDir() {
+		return "", fmt.Errorf("error expanding chart %s: expected a single chart directory", archive)
+	}
+	return filepath.Join(dir, entries[0].Name()), nil
+}
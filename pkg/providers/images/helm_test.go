@@ -0,0 +1,106 @@
+package images
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testChartDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .Release.Name }}-web
+  namespace: {{ .Release.Namespace }}
+spec:
+  replicas: {{ .Values.replicas }}
+  template:
+    spec:
+      containers:
+        - name: web
+          image: "{{ .Values.image.repository }}:{{ .Values.image.tag }}"
+{{- if .Values.sidecar.enabled }}
+        - name: sidecar
+          image: "sidecar:1.0.0"
+{{- end }}
+`
+
+func writeTestChart(t *testing.T) string {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "web")
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, "templates"), os.ModePerm))
+
+	files := map[string]string{
+		"Chart.yaml":                "apiVersion: v2\nname: web\nversion: 0.1.0\n",
+		"values.yaml":               "replicas: 2\nimage:\n  repository: example/web\n  tag: 1.0.0\nsidecar:\n  enabled: false\n",
+		"templates/deployment.yaml": testChartDeployment,
+		"templates/_helpers.tpl":    `{{- define "web.name" -}}web{{- end -}}`,
+		"templates/NOTES.txt":       "image: {{ .Values.image.repository }}",
+	}
+	for name, content := range files {
+		require.Nil(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+	}
+	return dir
+}
+
+func Test_HelmChartImages(t *testing.T) {
+
+	chart := writeTestChart(t)
+
+	valuesFile := filepath.Join(t.TempDir(), "values.yaml")
+	require.Nil(t, os.WriteFile(valuesFile, []byte("sidecar:\n  enabled: true\n"), 0600))
+
+	testCases := []struct {
+		desc     string
+		options  HelmOptions
+		expected map[string]string
+	}{
+		{
+			desc:     "default values",
+			options:  HelmOptions{ReleaseName: "release", Namespace: "default"},
+			expected: map[string]string{"example/web": "1.0.0"},
+		},
+		{
+			desc: "set overrides",
+			options: HelmOptions{
+				ReleaseName: "release",
+				Namespace:   "default",
+				Values:      []string{"image.tag=2.0.0"},
+			},
+			expected: map[string]string{"example/web": "2.0.0"},
+		},
+		{
+			desc: "values files",
+			options: HelmOptions{
+				ReleaseName: "release",
+				Namespace:   "other",
+				ValueFiles:  []string{valuesFile},
+			},
+			expected: map[string]string{"example/web": "1.0.0", "sidecar": "1.0.0"},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+
+			images, err := NewHelm(tC.options, chart).Images(context.Background())
+			require.Nil(t, err)
+
+			actual := map[string]string{}
+			for _, i := range images {
+				actual[i.Name] = i.Version
+				require.Equal(t, tC.options.Namespace, i.Destination.Namespace)
+				// replicas from the values
+				require.Equal(t, 2, i.Count)
+			}
+			require.Equal(t, tC.expected, actual)
+		})
+	}
+}
+
+func Test_HelmChartNotFound(t *testing.T) {
+	_, err := NewHelm(HelmOptions{}, filepath.Join(t.TempDir(), "missing")).Images(context.Background())
+	require.NotNil(t, err)
+}
@@ -3,22 +3,29 @@ package images
 import (
 	"context"
 	"fmt"
-	"strconv"
-	"strings"
+	"os"
+	"path/filepath"
 
 	"github.com/mdevilliers/org-scrounger/pkg/exec"
 	"github.com/mdevilliers/org-scrounger/pkg/mapping"
-	"github.com/vmware-labs/yaml-jsonpath/pkg/yamlpath"
-	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/konfig"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
 )
 
 type kustomize struct {
-	paths []string
+	// useBinary shells out to the kustomize binary rather than building in-process
+	useBinary bool
+	// imagePaths are extra JSONPaths to images in CRDs
+	imagePaths []string
+	paths      []string
 }
 
-func NewKustomize(paths ...string) *kustomize {
+func NewKustomize(useBinary bool, imagePaths []string, paths ...string) *kustomize {
 	return &kustomize{
-		paths: paths,
+		useBinary:  useBinary,
+		imagePaths: imagePaths,
+		paths:      paths,
 	}
 }
 
@@ -26,11 +33,11 @@ func (k *kustomize) Images(ctx context.Context) ([]mapping.Image, error) {
 	all := []mapping.Image{}
 
 	for _, path := range k.paths {
-		content, err := runKustomize(path)
+		content, err := buildKustomization(path, k.useBinary)
 		if err != nil {
-			return nil, fmt.Errorf("error running kustomize: %w at %s", err, path)
+			return nil, err // already wrapped
 		}
-		images, err := resolveImages(content, "unknown")
+		images, err := resolveImages(content, "unknown", k.imagePaths...)
 		if err != nil {
 			return nil, fmt.Errorf("error extracting images: %w", err)
 		}
@@ -41,115 +48,43 @@ func (k *kustomize) Images(ctx context.Context) ([]mapping.Image, error) {
 	return all, nil
 }
 
-// runKustomize shells out to a directory and returns a
-// big ball of yaml or an error
-func runKustomize(directory string) (string, error) {
-	return exec.GetCommandOutput(directory, "kustomize", "build")
-}
-
-// resolveImages produces a slice of Images or an error
-func resolveImages(probablyYaml, namespace string) ([]mapping.Image, error) { //nolint:funlen
-
-	all := map[string]mapping.Image{}
-
-	// split out to the individual documents
-	yamls := strings.Split(probablyYaml, "\n---\n")
-
-	for _, yamlstr := range yamls {
-		// extract all the .image values
-		var n yaml.Node
-
-		if err := yaml.Unmarshal([]byte(yamlstr), &n); err != nil {
-			return nil, fmt.Errorf("error unmarshalling yaml: %w", err)
-		}
-
-		namespaceElement, err := compileAndExecuteXpath("$..metadata.namespace", &n)
-		if err != nil {
-			return nil, fmt.Errorf("error running namespace xpath: %w", err)
-		}
-		if len(namespaceElement) == 1 {
-			namespace = namespaceElement[0].Value
-		}
-
-		specElements, err := compileAndExecuteXpath("$..spec", &n)
+// buildKustomization builds the kustomization in directory returning a
+// big ball of yaml or an error naming the kustomization file
+func buildKustomization(directory string, useBinary bool) (string, error) {
+	if useBinary {
+		content, err := runKustomize(directory)
 		if err != nil {
-			return nil, fmt.Errorf("error running spec xpath: %w", err)
+			return "", fmt.Errorf("error running kustomize: %w at %s", err, kustomizationFile(directory))
 		}
-		for _, spec := range specElements {
-			imageElements, err := compileAndExecuteXpath("$..image", spec)
-			if err != nil {
-				return nil, fmt.Errorf("error running image xpath: %w", err)
-			}
-			if len(imageElements) == 0 {
-				continue
-			}
-			// REVIEW : review this logic
-			replicas, err := parseReplicaCount(spec)
-			if err != nil {
-				return nil, fmt.Errorf("error running replicas xpath: %w", err)
-			}
-			for _, element := range imageElements {
-				image, version := splitImageAndVersion(strings.TrimSpace(element.Value))
-				i := mapping.Image{
-					Name:    image,
-					Version: version,
-					Count:   replicas,
-					Destination: &mapping.Destination{
-						Namespace: namespace,
-					},
-				}
-				key := fmt.Sprintf("%s_%s", i.Name, i.Version)
-				v, exists := all[key]
-				if !exists {
-					all[key] = i
-				} else {
-					v.Count++
-					all[key] = v
-				}
-			}
-		}
-	}
-
-	ret := []mapping.Image{}
-	for _, v := range all {
-		ret = append(ret, v)
+		return content, nil
 	}
 
-	return ret, nil
-}
-
-func parseReplicaCount(n *yaml.Node) (int, error) {
-	replicasElement, err := compileAndExecuteXpath(".replicas", n)
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
+	resources, err := k.Run(filesys.MakeFsOnDisk(), directory)
 	if err != nil {
-		return 0, fmt.Errorf("error running replicas xpath: %w", err)
+		return "", fmt.Errorf("error building kustomization: %w at %s", err, kustomizationFile(directory))
 	}
-	if len(replicasElement) == 1 {
-		return strconv.Atoi(replicasElement[0].Value)
+	content, err := resources.AsYaml()
+	if err != nil {
+		return "", fmt.Errorf("error serialising kustomization: %w at %s", err, kustomizationFile(directory))
 	}
-	// somethingelse but don't blow up
-	return 1, nil
+	return string(content), nil
 }
 
-func compileAndExecuteXpath(xpath string, document *yaml.Node) ([]*yaml.Node, error) {
-	path, err := yamlpath.NewPath(xpath)
-	if err != nil {
-		return nil, fmt.Errorf("error creating yaml path: %w", err)
+// kustomizationFile returns the path to the kustomization file in
+// directory or the directory if one can't be found
+func kustomizationFile(directory string) string {
+	for _, name := range konfig.RecognizedKustomizationFileNames() {
+		p := filepath.Join(directory, name)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
 	}
-	return path.Find(document)
+	return directory
 }
 
-func splitImageAndVersion(name string) (string, string) {
-
-	bits := strings.Split(name, ":")
-
-	imageName := bits[0]
-	version := "unknown"
-	if len(bits) == 2 { //nolint: gomnd
-		version = bits[1]
-	}
-	// deal with shas with versions
-	if len(bits) == 3 { //nolint: gomnd
-		version = fmt.Sprintf("%s:%s", bits[1], bits[2])
-	}
-	return imageName, version
+// runKustomize shells out to a directory and returns a
+// big ball of yaml or an error
+func runKustomize(directory string) (string, error) {
+	return exec.GetCommandOutput(directory, "kustomize", "build")
 }
@@ -0,0 +1,65 @@
+package images
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestKustomization(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		require.Nil(t, os.MkdirAll(filepath.Dir(p), os.ModePerm))
+		require.Nil(t, os.WriteFile(p, []byte(content), 0600))
+	}
+	return dir
+}
+
+func Test_KustomizeInProcess(t *testing.T) {
+
+	dir := writeTestKustomization(t, map[string]string{
+		"base/kustomization.yaml": "resources:\n  - deployment.yaml\n",
+		"base/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: example/web:1.0.0
+`,
+		"overlays/prod/kustomization.yaml": `namespace: prod
+resources:
+  - ../../base
+images:
+  - name: example/web
+    newTag: 2.0.0
+`,
+	})
+
+	images, err := NewKustomize(false, nil, filepath.Join(dir, "overlays", "prod")).Images(context.Background())
+	require.Nil(t, err)
+	require.Len(t, images, 1)
+	require.Equal(t, "example/web", images[0].Name)
+	require.Equal(t, "2.0.0", images[0].Version)
+	require.Equal(t, "prod", images[0].Destination.Namespace)
+}
+
+func Test_KustomizeErrorsNameTheKustomization(t *testing.T) {
+
+	dir := writeTestKustomization(t, map[string]string{
+		"kustomization.yml": "resources:\n  - missing.yaml\n",
+	})
+
+	_, err := NewKustomize(false, nil, dir).Images(context.Background())
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), filepath.Join(dir, "kustomization.yml"))
+}
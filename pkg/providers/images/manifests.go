@@ -0,0 +1,143 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/gobwas/glob"
+	"github.com/mdevilliers/org-scrounger/pkg/mapping"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultManifestIncludes are used when no include globs are specified
+var defaultManifestIncludes = []string{"*.yaml", "*.yml", "*.json"}
+
+type manifests struct {
+	paths []string
+	// imagePaths are extra JSONPaths to images in CRDs
+	imagePaths []string
+	includes   []glob.Glob
+	excludes   []glob.Glob
+}
+
+// NewManifests returns a provider that reads raw Kubernetes manifests from the paths.
+// Directories are walked recursively, files are read if they match one of the includes
+// and none of the excludes. Globs are matched against both the path relative to the
+// directory and the file name e.g. '*.yaml' or 'overlays/prod/**'.
+func NewManifests(includes, excludes, imagePaths []string, paths ...string) (*manifests, error) {
+	if len(includes) == 0 {
+		includes = defaultManifestIncludes
+	}
+	m := &manifests{
+		paths:      paths,
+		imagePaths: imagePaths,
+	}
+	var err error
+	if m.includes, err = compileGlobs(includes); err != nil {
+		return nil, err
+	}
+	if m.excludes, err = compileGlobs(excludes); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *manifests) Images(ctx context.Context) ([]mapping.Image, error) {
+	all := []mapping.Image{}
+
+	for _, path := range m.paths {
+		files, err := m.files(path)
+		if err != nil {
+			return nil, fmt.Errorf("error finding manifests: %w at %s", err, path)
+		}
+
+		documents := []*yaml.Node{}
+		for _, f := range files {
+			data, err := os.ReadFile(f)
+			if err != nil {
+				return nil, fmt.Errorf("error reading manifest: %w", err)
+			}
+			// parsed per file so errors point to the offending file
+			parsed, err := parseDocuments(string(data))
+			if err != nil {
+				return nil, fmt.Errorf("error extracting images: %w at %s", err, f)
+			}
+			documents = append(documents, parsed...)
+		}
+
+		images, err := resolveDocumentImages(documents, "unknown", m.imagePaths...)
+		if err != nil {
+			return nil, fmt.Errorf("error extracting images: %w", err)
+		}
+
+		all = append(all, images...)
+
+	}
+	return all, nil
+}
+
+// files returns the sorted manifest files found at root
+func (m *manifests) files(root string) ([]string, error) {
+	stat, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.IsDir() {
+		// explicitly named files are always read
+		return []string{root}, nil
+	}
+
+	all := []string{}
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if matchesAny(m.includes, rel, d.Name()) && !matchesAny(m.excludes, rel, d.Name()) {
+			all = append(all, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(all)
+	return all, nil
+}
+
+func compileGlobs(patterns []string) ([]glob.Glob, error) {
+	all := []glob.Glob{}
+	for _, p := range patterns {
+		g, err := glob.Compile(p, '/')
+		if err != nil {
+			return nil, fmt.Errorf("error compiling glob '%s': %w", p, err)
+		}
+		all = append(all, g)
+	}
+	return all, nil
+}
+
+func matchesAny(globs []glob.Glob, values ...string) bool {
+	for _, g := range globs {
+		for _, v := range values {
+			if g.Match(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package images
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestManifests(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"web.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+spec:
+  template:
+    spec:
+      containers:
+        - name: web
+          image: example/web:1.0.0
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+    - port: 80
+`,
+		"jobs/cron.json": `{"apiVersion": "batch/v1", "kind": "CronJob", "metadata": {"name": "cron"},
+ "spec": {"jobTemplate": {"spec": {"template": {"spec": {"containers": [{"name": "cron", "image": "example/cron:2.0.0"}]}}}}}}`,
+		"tests/fixture.yaml": `spec:
+  containers:
+    - image: example/test:0.0.1
+`,
+		"readme.md":          "image: example/readme:1.0.0",
+		".git/config.yaml":   "spec: {image: example/git:1.0.0}",
+		"broken/broken.yaml": "spec: [",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		require.Nil(t, os.MkdirAll(filepath.Dir(p), os.ModePerm))
+		require.Nil(t, os.WriteFile(p, []byte(content), 0600))
+	}
+	return dir
+}
+
+func Test_ManifestImages(t *testing.T) {
+
+	dir := writeTestManifests(t)
+
+	testCases := []struct {
+		desc     string
+		includes []string
+		excludes []string
+		paths    []string
+		expected []string
+	}{
+		{
+			desc:     "excludes by path and name",
+			excludes: []string{"tests/**", "broken.yaml"},
+			paths:    []string{dir},
+			expected: []string{"example/cron", "example/web"},
+		},
+		{
+			desc:     "includes",
+			includes: []string{"*.json"},
+			paths:    []string{dir},
+			expected: []string{"example/cron"},
+		},
+		{
+			desc:     "files are always read",
+			paths:    []string{filepath.Join(dir, "tests", "fixture.yaml")},
+			expected: []string{"example/test"},
+		},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+
+			provider, err := NewManifests(tC.includes, tC.excludes, nil, tC.paths...)
+			require.Nil(t, err)
+
+			images, err := provider.Images(context.Background())
+			require.Nil(t, err)
+
+			actual := []string{}
+			for _, i := range images {
+				actual = append(actual, i.Name)
+			}
+			require.ElementsMatch(t, tC.expected, actual)
+		})
+	}
+}
+
+func Test_ManifestErrorsNameTheFile(t *testing.T) {
+
+	dir := writeTestManifests(t)
+
+	provider, err := NewManifests(nil, nil, nil, dir)
+	require.Nil(t, err)
+
+	_, err = provider.Images(context.Background())
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "broken.yaml")
+}
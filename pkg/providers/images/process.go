@@ -0,0 +1,22 @@
+//go:build !windows
+
+package images
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// isProcessAlive returns true if the process exists, even if owned by another user
+func isProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = p.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
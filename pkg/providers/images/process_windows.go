@@ -0,0 +1,28 @@
+package images
+
+import (
+	"errors"
+	"syscall"
+)
+
+// stillActive is the exit code of a process that hasn't exited
+const stillActive = 259
+
+// isProcessAlive returns true if the process exists, even if owned by another user
+func isProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	h, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// the process exists but can't be queried
+		return errors.Is(err, syscall.ERROR_ACCESS_DENIED)
+	}
+	defer syscall.CloseHandle(h) //nolint: errcheck
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
@@ -0,0 +1,61 @@
+package images
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mdevilliers/org-scrounger/pkg/mapping"
+	"github.com/mdevilliers/org-scrounger/pkg/registry"
+	"github.com/rs/zerolog/log"
+)
+
+type registryProvider struct {
+	client       *registry.Client
+	repositories []string
+}
+
+// NewRegistry returns a provider listing the tags of the repositories in the container
+// repositories. A container repository is either a registry e.g. 'registry:5000' or a
+// registry and the leading components of the repositories e.g. 'registry:5000/team'.
+func NewRegistry(client *registry.Client, containerRepositories ...string) *registryProvider {
+	return &registryProvider{
+		client:       client,
+		repositories: containerRepositories,
+	}
+}
+
+func (r *registryProvider) Images(ctx context.Context) ([]mapping.Image, error) {
+	all := []mapping.Image{}
+
+	for _, containerRepository := range r.repositories {
+		host, prefix, _ := strings.Cut(strings.TrimSuffix(containerRepository, "/"), "/")
+		if !isRegistryHost(host) {
+			log.Warn().Msgf("container repository doesn't name a registry: %s", containerRepository)
+			continue
+		}
+
+		repositories, err := r.client.Catalog(ctx, host)
+		if err != nil {
+			return nil, err // already wrapped
+		}
+		for _, repository := range repositories {
+			if prefix != "" && !strings.HasPrefix(repository, prefix+"/") {
+				continue
+			}
+			tags, err := r.client.Tags(ctx, host, repository)
+			if err != nil {
+				return nil, err // already wrapped
+			}
+			for _, tag := range tags {
+				all = append(all, mapping.NewImage(fmt.Sprintf("%s/%s:%s", host, repository, tag)))
+			}
+		}
+	}
+	return all, nil
+}
+
+// isRegistryHost returns true if the component names a registry rather than a repository
+func isRegistryHost(host string) bool {
+	return strings.ContainsAny(host, ".:") || host == "localhost"
+}
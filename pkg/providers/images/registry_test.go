@@ -0,0 +1,32 @@
+package images
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mdevilliers/org-scrounger/pkg/registry"
+	"github.com/mdevilliers/org-scrounger/pkg/registry/registrytest"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_RegistryImages(t *testing.T) {
+
+	r := registrytest.New(t)
+	r.Push("team/web", "1.0.0", nil)
+	r.Push("team/web", "1.1.0", nil)
+	r.Push("team/api", "2.0.0", nil)
+	r.Push("other/worker", "3.0.0", nil)
+
+	provider := NewRegistry(registry.NewClient(), r.Host()+"/team", "not-a-registry")
+
+	images, err := provider.Images(context.Background())
+	require.Nil(t, err)
+
+	actual := []string{}
+	for _, i := range images {
+		require.Equal(t, r.Host(), i.Registry)
+		require.Equal(t, 0, i.Count)
+		actual = append(actual, i.Repository+":"+i.Version)
+	}
+	require.Equal(t, []string{"team/api:2.0.0", "team/web:1.0.0", "team/web:1.1.0"}, actual)
+}
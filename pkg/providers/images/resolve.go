@@ -0,0 +1,227 @@
+package images
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mdevilliers/org-scrounger/pkg/mapping"
+	"github.com/vmware-labs/yaml-jsonpath/pkg/yamlpath"
+	"gopkg.in/yaml.v3"
+)
+
+// podSpecContainers maps the container fields of a pod spec to their container type
+var podSpecContainers = []struct {
+	field         string
+	containerType string
+}{
+	{field: "initContainers", containerType: mapping.ContainerTypeInit},
+	{field: "containers", containerType: mapping.ContainerTypeMain},
+	{field: "ephemeralContainers", containerType: mapping.ContainerTypeEphemeral},
+}
+
+// imageSet aggregates the images of workloads by reference and namespace
+type imageSet map[string]mapping.Image
+
+// add records the container of the workload running image in namespace
+func (s imageSet) add(image, namespace string, workload mapping.Workload) {
+	i := mapping.NewImage(image)
+	key := fmt.Sprintf("%s_%s", imageKey(i), namespace)
+	v, exists := s[key]
+	if !exists {
+		v = i
+		v.Destination = &mapping.Destination{
+			Namespace: namespace,
+		}
+	}
+	v.Count += workload.Replicas
+	v.Workloads = append(v.Workloads, workload)
+	s[key] = v
+}
+
+// images returns the images sorted by reference and namespace
+func (s imageSet) images() []mapping.Image {
+	keys := []string{}
+	for k := range s {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	ret := []mapping.Image{}
+	for _, k := range keys {
+		ret = append(ret, s[k])
+	}
+	return ret
+}
+
+// resolveImages produces a slice of Images or an error. Images are found in the containers
+// of any pod spec e.g. Deployments, CronJobs or CRDs such as Rollouts and KNative Services.
+// imagePaths are extra JSONPaths, evaluated against each document, to images elsewhere in CRDs.
+func resolveImages(probablyYaml, defaultNamespace string, imagePaths ...string) ([]mapping.Image, error) {
+	documents, err := parseDocuments(probablyYaml)
+	if err != nil {
+		return nil, err
+	}
+	return resolveDocumentImages(documents, defaultNamespace, imagePaths...)
+}
+
+// parseDocuments parses each of the documents in the stream, empty documents are skipped
+func parseDocuments(probablyYaml string) ([]*yaml.Node, error) {
+	documents := []*yaml.Node{}
+
+	decoder := yaml.NewDecoder(strings.NewReader(probablyYaml))
+	for {
+		var n yaml.Node
+
+		if err := decoder.Decode(&n); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("error unmarshalling yaml: %w", err)
+		}
+		if len(n.Content) == 0 {
+			continue
+		}
+		documents = append(documents, n.Content[0])
+	}
+	return documents, nil
+}
+
+// resolveDocumentImages returns the images in the parsed documents, see resolveImages
+func resolveDocumentImages(documents []*yaml.Node, defaultNamespace string, imagePaths ...string) ([]mapping.Image, error) {
+
+	paths := []*yamlpath.Path{}
+	for _, p := range imagePaths {
+		path, err := yamlpath.NewPath(p)
+		if err != nil {
+			return nil, fmt.Errorf("error creating yaml path '%s': %w", p, err)
+		}
+		paths = append(paths, path)
+	}
+
+	all := imageSet{}
+
+	for _, document := range documents {
+		namespace := defaultNamespace
+		if ns := scalarAt(document, "metadata", "namespace"); ns != "" {
+			namespace = ns
+		}
+		replicas, err := parseReplicaCount(document)
+		if err != nil {
+			return nil, err
+		}
+		workload := mapping.Workload{
+			Kind:     scalarAt(document, "kind"),
+			Name:     scalarAt(document, "metadata", "name"),
+			Replicas: replicas,
+		}
+
+		for _, spec := range findPodSpecs(document) {
+			for _, c := range podSpecContainers {
+				for _, container := range sequenceAt(spec, c.field) {
+					image := scalarAt(container, "image")
+					if image == "" {
+						continue
+					}
+					w := workload
+					w.Container = scalarAt(container, "name")
+					w.ContainerType = c.containerType
+					// init containers that keep running are native sidecars
+					if c.containerType == mapping.ContainerTypeInit && scalarAt(container, "restartPolicy") == "Always" {
+						w.ContainerType = mapping.ContainerTypeSidecar
+					}
+					all.add(image, namespace, w)
+				}
+			}
+		}
+
+		for _, path := range paths {
+			elements, err := path.Find(document)
+			if err != nil {
+				return nil, fmt.Errorf("error running image yaml path: %w", err)
+			}
+			for _, element := range elements {
+				if element.Kind != yaml.ScalarNode || element.Value == "" {
+					continue
+				}
+				w := workload
+				w.ContainerType = mapping.ContainerTypeCustom
+				all.add(element.Value, namespace, w)
+			}
+		}
+	}
+
+	return all.images(), nil
+}
+
+// findPodSpecs returns the mappings with a containers sequence under n
+func findPodSpecs(n *yaml.Node) []*yaml.Node {
+	if n.Kind == yaml.MappingNode && len(sequenceAt(n, "containers")) > 0 {
+		return []*yaml.Node{n}
+	}
+	ret := []*yaml.Node{}
+	for _, c := range n.Content {
+		ret = append(ret, findPodSpecs(c)...)
+	}
+	return ret
+}
+
+// valueAt returns the node at the keys of nested mappings or nil
+func valueAt(n *yaml.Node, keys ...string) *yaml.Node {
+	for _, key := range keys {
+		if n == nil || n.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == key {
+				next = n.Content[i+1]
+				break
+			}
+		}
+		n = next
+	}
+	return n
+}
+
+// scalarAt returns the value of the scalar at the keys or an empty string
+func scalarAt(n *yaml.Node, keys ...string) string {
+	v := valueAt(n, keys...)
+	if v == nil || v.Kind != yaml.ScalarNode {
+		return ""
+	}
+	return v.Value
+}
+
+// sequenceAt returns the items of the sequence at the keys
+func sequenceAt(n *yaml.Node, keys ...string) []*yaml.Node {
+	v := valueAt(n, keys...)
+	if v == nil || v.Kind != yaml.SequenceNode {
+		return nil
+	}
+	return v.Content
+}
+
+// parseReplicaCount returns the replicas of the workload or 1 if not specified
+func parseReplicaCount(document *yaml.Node) (int, error) {
+	replicas := scalarAt(document, "spec", "replicas")
+	if replicas == "" {
+		return 1, nil
+	}
+	count, err := strconv.Atoi(replicas)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing replicas: %w", err)
+	}
+	return count, nil
+}
+
+// imageKey returns the key used to group images by their reference
+func imageKey(i mapping.Image) string {
+	if i.Repository == "" {
+		return i.Name
+	}
+	return i.Reference().String()
+}
@@ -0,0 +1,159 @@
+package images
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mdevilliers/org-scrounger/pkg/mapping"
+	"github.com/stretchr/testify/require"
+)
+
+const testWorkloads = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+spec:
+  replicas: 3
+  template:
+    spec:
+      initContainers:
+        - name: migrate
+          image: example/web:1.0.0
+        - name: proxy
+          image: example/proxy:1.0.0
+          restartPolicy: Always
+      containers:
+        - name: web
+          image: example/web:1.0.0
+---
+apiVersion: argoproj.io/v1alpha1
+kind: Rollout
+metadata:
+  name: api
+  namespace: prod
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+        - name: api
+          image: example/api:2.0.0
+        - name: proxy
+          image: example/proxy:1.0.0
+---
+apiVersion: serving.knative.dev/v1
+kind: Service
+metadata:
+  name: hello
+spec:
+  template:
+    spec:
+      containers:
+        - image: example/hello:1.0.0
+---
+apiVersion: monitoring.coreos.com/v1
+kind: Prometheus
+metadata:
+  name: prometheus
+  namespace: monitoring
+spec:
+  replicas: 2
+  image: quay.io/prometheus/prometheus:v2.50.0
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+data:
+  image: example/not-an-image:1.0.0
+`
+
+func Test_ResolveImages(t *testing.T) {
+
+	images, err := resolveImages(testWorkloads, "default", "$.spec.image")
+	require.Nil(t, err)
+
+	actual := map[string]mapping.Image{}
+	for _, i := range images {
+		actual[i.Name+"_"+i.Destination.Namespace] = i
+	}
+	require.Len(t, actual, 5)
+
+	web := actual["example/web_prod"]
+	require.Equal(t, 6, web.Count)
+	require.Equal(t, []mapping.Workload{
+		{Kind: "Deployment", Name: "web", Container: "migrate", ContainerType: mapping.ContainerTypeInit, Replicas: 3},
+		{Kind: "Deployment", Name: "web", Container: "web", ContainerType: mapping.ContainerTypeMain, Replicas: 3},
+	}, web.Workloads)
+
+	proxy := actual["example/proxy_prod"]
+	require.Equal(t, 5, proxy.Count)
+	require.Equal(t, []mapping.Workload{
+		{Kind: "Deployment", Name: "web", Container: "proxy", ContainerType: mapping.ContainerTypeSidecar, Replicas: 3},
+		{Kind: "Rollout", Name: "api", Container: "proxy", ContainerType: mapping.ContainerTypeMain, Replicas: 2},
+	}, proxy.Workloads)
+
+	require.Equal(t, 2, actual["example/api_prod"].Count)
+
+	hello := actual["example/hello_default"]
+	require.Equal(t, 1, hello.Count)
+	require.Equal(t, "Service", hello.Workloads[0].Kind)
+
+	prometheus := actual["quay.io/prometheus/prometheus_monitoring"]
+	require.Equal(t, "v2.50.0", prometheus.Version)
+	require.Equal(t, 2, prometheus.Count)
+	require.Equal(t, mapping.ContainerTypeCustom, prometheus.Workloads[0].ContainerType)
+}
+
+func Test_ResolveImagesDocumentSeparators(t *testing.T) {
+
+	pod := func(name string) string {
+		return "apiVersion: v1\nkind: Pod\nmetadata:\n  name: " + name + "\nspec:\n  containers:\n    - image: example/" + name + ":1.0.0\n"
+	}
+
+	testCases := []struct {
+		desc string
+		yaml string
+	}{
+		{desc: "separator", yaml: pod("one") + "---\n" + pod("two")},
+		{desc: "separator with a comment", yaml: pod("one") + "--- # second pod\n" + pod("two")},
+		{desc: "separator with trailing whitespace", yaml: pod("one") + "--- \n" + pod("two")},
+		{desc: "leading separator", yaml: "---\n" + pod("one") + "---\n" + pod("two")},
+		{desc: "crlf line endings", yaml: strings.ReplaceAll(pod("one")+"---\n"+pod("two"), "\n", "\r\n")},
+		{desc: "empty documents", yaml: pod("one") + "---\n---\n" + pod("two") + "---\n"},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			images, err := resolveImages(tC.yaml, "default")
+			require.Nil(t, err)
+
+			workloads := []string{}
+			for _, i := range images {
+				for _, w := range i.Workloads {
+					workloads = append(workloads, w.Kind+"/"+w.Name)
+				}
+			}
+			require.Equal(t, []string{"Pod/one", "Pod/two"}, workloads)
+		})
+	}
+}
+
+func Test_ResolveImagesErrors(t *testing.T) {
+
+	testCases := []struct {
+		desc       string
+		yaml       string
+		imagePaths []string
+	}{
+		{desc: "invalid yaml", yaml: "spec: ["},
+		{desc: "invalid replicas", yaml: "spec:\n  replicas: many\n  containers:\n    - image: example/web:1.0.0\n"},
+		{desc: "invalid image path", yaml: "spec: {}", imagePaths: []string{"$.spec[?("}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			_, err := resolveImages(tC.yaml, "default", tC.imagePaths...)
+			require.NotNil(t, err)
+		})
+	}
+}
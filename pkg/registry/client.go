@@ -0,0 +1,296 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultTokenExpiry is used if the token response doesn't include expires_in
+	defaultTokenExpiry = 60 * time.Second
+	// tokenExpiryLeeway refreshes tokens before they expire mid request
+	tokenExpiryLeeway = 10 * time.Second
+)
+
+var (
+	// challengeParam matches the parameters of a WWW-Authenticate challenge e.g. realm="https://auth.example.com/token"
+	challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+	// trustedRealms are the token servers of registries on another host
+	trustedRealms = map[string]string{
+		"registry-1.docker.io": "auth.docker.io",
+	}
+)
+
+// Client queries the OCI Distribution API of container registries
+type Client struct {
+	// HTTP client used to communicate with the API. By default
+	// http.DefaultClient will be used.
+	httpClient *http.Client
+
+	// username and password are only sent to the registry, or its token server
+	registry string
+	username string
+	password string
+	// plainHTTP are the registries accessed over http rather than https
+	plainHTTP map[string]bool
+	now       func() time.Time
+
+	mu sync.Mutex
+	// tokens are the bearer tokens keyed by registry, realm, service and scope
+	tokens map[string]bearerToken
+}
+
+type bearerToken struct {
+	value   string
+	expires time.Time
+}
+
+// Option can be supplied that override the default Clients properties
+type Option func(c *Client)
+
+// WithHTTPClient allows a specific http.Client to be set
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithBasicAuth sets the credentials used for basic auth or to request bearer tokens
+// from the registry. Other registries are accessed anonymously.
+func WithBasicAuth(registry, username, password string) Option {
+	return func(c *Client) {
+		c.registry, _ = Normalize(registry, "")
+		c.username = username
+		c.password = password
+	}
+}
+
+// WithPlainHTTP accesses the registries over http. Loopback registries
+// e.g. 'localhost:5000' are always accessed over http.
+func WithPlainHTTP(registries ...string) Option {
+	return func(c *Client) {
+		for _, r := range registries {
+			c.plainHTTP[r] = true
+		}
+	}
+}
+
+// NewClient returns a new registry client
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		httpClient: http.DefaultClient,
+		plainHTTP:  map[string]bool{},
+		now:        time.Now,
+		tokens:     map[string]bearerToken{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// baseURL returns the root of the API of the registry
+func (c *Client) baseURL(registry string) string {
+	scheme := "https"
+	if c.plainHTTP[registry] || isLoopback(registry) {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s", scheme, registry)
+}
+
+// get requests the path, relative to the registry, authenticating if challenged.
+// The body is JSON decoded into v if v isn't nil.
+func (c *Client) get(ctx context.Context, registry, path string, accept []string, v interface{}) (*http.Response, error) {
+	return c.request(ctx, http.MethodGet, registry, path, accept, v)
+}
+
+// head requests the headers of the path, relative to the registry, authenticating if challenged
+func (c *Client) head(ctx context.Context, registry, path string, accept []string) (*http.Response, error) {
+	return c.request(ctx, http.MethodHead, registry, path, accept, nil)
+}
+
+func (c *Client) request(ctx context.Context, method, registry, path string, accept []string, v interface{}) (*http.Response, error) {
+	return c.requestURL(ctx, method, registry, c.baseURL(registry)+path, accept, v)
+}
+
+// requestURL requests the absolute URL u of the registry, authenticating if challenged
+func (c *Client) requestURL(ctx context.Context, method, registry, u string, accept []string, v interface{}) (*http.Response, error) {
+
+	response, err := c.do(ctx, method, u, accept, "")
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode == http.StatusUnauthorized {
+		challenge := response.Header.Get("WWW-Authenticate")
+		response.Body.Close()
+
+		authorization, err := c.authorize(ctx, registry, challenge)
+		if err != nil {
+			return nil, fmt.Errorf("error authenticating with %s: %w", registry, err)
+		}
+		response, err = c.do(ctx, method, u, accept, authorization)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 400 { //nolint:gomnd
+		return response, fmt.Errorf("error calling registry %s : %d", u, response.StatusCode)
+	}
+	if v != nil {
+		if err := json.NewDecoder(response.Body).Decode(v); err != nil && err != io.EOF {
+			return response, fmt.Errorf("error decoding response from %s: %w", u, err)
+		}
+	}
+	return response, nil
+}
+
+func (c *Client) do(ctx context.Context, method, u string, accept []string, authorization string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, method, u, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range accept {
+		request.Header.Add("Accept", a)
+	}
+	if authorization != "" {
+		request.Header.Set("Authorization", authorization)
+	}
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("error calling registry %s: %w", u, err)
+	}
+	return response, nil
+}
+
+// hasCredentials returns true if the credentials are for the registry
+func (c *Client) hasCredentials(registry string) bool {
+	return c.username != "" && c.registry == registry
+}
+
+// authorize returns the Authorization header answering the challenge of the registry
+func (c *Client) authorize(ctx context.Context, registry, challenge string) (string, error) {
+
+	scheme, params, _ := strings.Cut(challenge, " ")
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if !c.hasCredentials(registry) {
+			return "", fmt.Errorf("credentials required")
+		}
+		request, _ := http.NewRequest(http.MethodGet, "/", http.NoBody)
+		request.SetBasicAuth(c.username, c.password)
+		return request.Header.Get("Authorization"), nil
+	case "bearer":
+		token, err := c.token(ctx, registry, parseChallenge(params))
+		if err != nil {
+			return "", err
+		}
+		return "Bearer " + token, nil
+	}
+	return "", fmt.Errorf("unsupported challenge '%s'", challenge)
+}
+
+// token requests a bearer token from the realm of the challenge of the registry. Credentials are
+// only sent if they are for the registry and the realm is on the same host or a trusted token server.
+func (c *Client) token(ctx context.Context, registry string, params map[string]string) (string, error) {
+
+	realm := params["realm"]
+	if realm == "" {
+		return "", fmt.Errorf("bearer challenge without a realm")
+	}
+	key := fmt.Sprintf("%s|%s|%s|%s", registry, realm, params["service"], params["scope"])
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if token, found := c.tokens[key]; found && c.now().Before(token.expires) {
+		return token.value, nil
+	}
+
+	u, err := url.Parse(realm)
+	if err != nil {
+		return "", fmt.Errorf("error parsing realm %s: %w", realm, err)
+	}
+	query := u.Query()
+	for _, p := range []string{"service", "scope"} {
+		if params[p] != "" {
+			query.Set(p, params[p])
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return "", err
+	}
+	if c.hasCredentials(registry) && isTrustedRealm(registry, u) {
+		request.SetBasicAuth(c.username, c.password)
+	}
+	issued := c.now()
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return "", fmt.Errorf("error requesting token: %w", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 400 { //nolint:gomnd
+		return "", fmt.Errorf("error requesting token : %d", response.StatusCode)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("error decoding token: %w", err)
+	}
+	token := bearerToken{value: body.Token, expires: issued.Add(defaultTokenExpiry - tokenExpiryLeeway)}
+	if token.value == "" {
+		token.value = body.AccessToken
+	}
+	if body.ExpiresIn > 0 {
+		token.expires = issued.Add(time.Duration(body.ExpiresIn)*time.Second - tokenExpiryLeeway)
+	}
+	c.tokens[key] = token
+	return token.value, nil
+}
+
+// isTrustedRealm returns true if the token server is on the host of the registry or is trusted by it
+func isTrustedRealm(registry string, realm *url.URL) bool {
+	host, _, err := net.SplitHostPort(registry)
+	if err != nil {
+		host = registry
+	}
+	return realm.Host == registry || realm.Hostname() == host && realm.Port() == "" || trustedRealms[registry] == realm.Host
+}
+
+// parseChallenge returns the parameters of a challenge
+func parseChallenge(params string) map[string]string {
+	ret := map[string]string{}
+	for _, m := range challengeParam.FindAllStringSubmatch(params, -1) {
+		ret[m[1]] = m[2]
+	}
+	return ret
+}
+
+// isLoopback returns true for registries on the local machine
+func isLoopback(registry string) bool {
+	host, _, err := net.SplitHostPort(registry)
+	if err != nil {
+		host = registry
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
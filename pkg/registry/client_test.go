@@ -0,0 +1,154 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/mdevilliers/org-scrounger/pkg/registry/registrytest"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_CatalogAndTags(t *testing.T) {
+
+	r := registrytest.New(t)
+	for i := 0; i < 150; i++ {
+		r.Push(fmt.Sprintf("team/app-%03d", i), "1.0.0", nil)
+	}
+	r.Push("team/app-000", "1.1.0", nil)
+
+	client := NewClient()
+	ctx := context.Background()
+
+	// paginated over two pages
+	repos, err := client.Catalog(ctx, r.Host())
+	require.Nil(t, err)
+	require.Len(t, repos, 150)
+	require.Equal(t, "team/app-149", repos[149])
+
+	tags, err := client.Tags(ctx, r.Host(), "team/app-000")
+	require.Nil(t, err)
+	require.Equal(t, []string{"1.0.0", "1.1.0"}, tags)
+
+	_, err = client.Tags(ctx, r.Host(), "team/missing")
+	require.NotNil(t, err)
+}
+
+func Test_BearerAuth(t *testing.T) {
+
+	r := registrytest.New(t)
+	r.Username = "user"
+	r.Password = "secret"
+	r.Push("app", "1.0.0", nil)
+
+	ctx := context.Background()
+
+	_, err := NewClient().Catalog(ctx, r.Host())
+	require.NotNil(t, err)
+
+	_, err = NewClient(WithBasicAuth(r.Host(), "user", "wrong")).Catalog(ctx, r.Host())
+	require.NotNil(t, err)
+
+	repos, err := NewClient(WithBasicAuth(r.Host(), "user", "secret")).Catalog(ctx, r.Host())
+	require.Nil(t, err)
+	require.Equal(t, []string{"app"}, repos)
+
+	// the credentials are for another registry
+	_, err = NewClient(WithBasicAuth("ghcr.io", "user", "secret")).Catalog(ctx, r.Host())
+	require.NotNil(t, err)
+}
+
+func Test_CredentialsAreOnlySentToTheRegistry(t *testing.T) {
+
+	// a token server on another host
+	sent := make(chan bool, 1)
+	realm := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _, ok := req.BasicAuth()
+		sent <- ok
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer realm.Close()
+
+	r := registrytest.New(t)
+	r.Username = "user"
+	r.Password = "secret"
+	r.Realm = realm.URL + "/token"
+
+	_, err := NewClient(WithBasicAuth(r.Host(), "user", "secret")).Catalog(context.Background(), r.Host())
+	require.NotNil(t, err)
+	require.False(t, <-sent)
+}
+
+func Test_TokensAreRefreshedOnceExpired(t *testing.T) {
+
+	r := registrytest.New(t)
+	r.Username = "user"
+	r.Password = "secret"
+	r.ExpiresIn = 300
+	r.Push("app", "1.0.0", nil)
+
+	now := time.Now()
+	client := NewClient(WithBasicAuth(r.Host(), "user", "secret"))
+	client.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		_, err := client.Catalog(ctx, r.Host())
+		require.Nil(t, err)
+	}
+	require.Equal(t, 1, r.TokenRequests())
+
+	now = now.Add(5 * time.Minute)
+	_, err := client.Catalog(ctx, r.Host())
+	require.Nil(t, err)
+	require.Equal(t, 2, r.TokenRequests())
+}
+
+func Test_AbsoluteLinks(t *testing.T) {
+
+	r := registrytest.New(t)
+	r.AbsoluteLinks = true
+	for i := 0; i < 150; i++ {
+		r.Push(fmt.Sprintf("team/app-%03d", i), "1.0.0", nil)
+	}
+
+	repos, err := NewClient().Catalog(context.Background(), r.Host())
+	require.Nil(t, err)
+	require.Len(t, repos, 150)
+}
+
+func Test_IsTrustedRealm(t *testing.T) {
+	testCases := []struct {
+		registry string
+		realm    string
+		expected bool
+	}{
+		{registry: "ghcr.io", realm: "https://ghcr.io/token", expected: true},
+		{registry: "registry:5000", realm: "http://registry:5000/token", expected: true},
+		{registry: "registry:5000", realm: "https://registry/token", expected: true},
+		{registry: "registry-1.docker.io", realm: "https://auth.docker.io/token", expected: true},
+		{registry: "ghcr.io", realm: "https://auth.docker.io/token", expected: false},
+		{registry: "registry:5000", realm: "http://registry:6000/token", expected: false},
+		{registry: "ghcr.io", realm: "https://evil.example.com/token", expected: false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.registry+" "+tC.realm, func(t *testing.T) {
+			u, err := url.Parse(tC.realm)
+			require.Nil(t, err)
+			require.Equal(t, tC.expected, isTrustedRealm(tC.registry, u))
+		})
+	}
+}
+
+func Test_ParseChallenge(t *testing.T) {
+	params := parseChallenge(`realm="https://auth.docker.io/token",service="registry.docker.io",scope="repository:library/nginx:pull"`)
+	require.Equal(t, map[string]string{
+		"realm":   "https://auth.docker.io/token",
+		"service": "registry.docker.io",
+		"scope":   "repository:library/nginx:pull",
+	}, params)
+}
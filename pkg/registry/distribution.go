@@ -0,0 +1,174 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// pageSize is the number of results requested per page
+const pageSize = 100
+
+// nextLink matches the next page of a Link header e.g. </v2/_catalog?last=b&n=100>; rel="next"
+var nextLink = regexp.MustCompile(`<([^>]+)>;\s*rel="?next"?`)
+
+// Catalog returns the repositories in the registry
+func (c *Client) Catalog(ctx context.Context, registry string) ([]string, error) {
+	ret := []string{}
+	var page struct {
+		Repositories []string `json:"repositories"`
+	}
+	err := c.paginate(ctx, registry, fmt.Sprintf("/v2/_catalog?n=%d", pageSize), &page, func() {
+		ret = append(ret, page.Repositories...)
+		page.Repositories = nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing repositories in %s: %w", registry, err)
+	}
+	return ret, nil
+}
+
+// Tags returns the tags of the repository in the registry
+func (c *Client) Tags(ctx context.Context, registry, repository string) ([]string, error) {
+	ret := []string{}
+	var page struct {
+		Tags []string `json:"tags"`
+	}
+	err := c.paginate(ctx, registry, fmt.Sprintf("/v2/%s/tags/list?n=%d", repository, pageSize), &page, func() {
+		ret = append(ret, page.Tags...)
+		page.Tags = nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing tags of %s/%s: %w", registry, repository, err)
+	}
+	return ret, nil
+}
+
+// paginate decodes each page into v, calling collect after each page, following the
+// Link headers of the responses. Links are resolved relative to the page linking to them.
+func (c *Client) paginate(ctx context.Context, registry, path string, v interface{}, collect func()) error {
+	next, err := url.Parse(c.baseURL(registry) + path)
+	if err != nil {
+		return fmt.Errorf("error parsing url: %w", err)
+	}
+	for next != nil {
+		current := next
+		response, err := c.requestURL(ctx, http.MethodGet, registry, current.String(), nil, v)
+		if err != nil {
+			return err
+		}
+		collect()
+
+		next = nil
+		if m := nextLink.FindStringSubmatch(response.Header.Get("Link")); m != nil {
+			link, err := url.Parse(m[1])
+			if err != nil {
+				return fmt.Errorf("error parsing link %s: %w", m[1], err)
+			}
+			next = current.ResolveReference(link)
+			// the credentials of the registry mustn't be sent elsewhere
+			if next.Host != current.Host {
+				return fmt.Errorf("error following link %s: not on %s", next, current.Host)
+			}
+		}
+	}
+	return nil
+}
+
+// manifestMediaTypes are the manifests and indexes accepted when resolving a digest
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Digest returns the digest of the manifest of reference, a tag or digest, in the repository
+func (c *Client) Digest(ctx context.Context, registry, repository, reference string) (string, error) {
+	response, err := c.head(ctx, registry, fmt.Sprintf("/v2/%s/manifests/%s", repository, reference), manifestMediaTypes)
+	if err != nil {
+		return "", fmt.Errorf("error resolving %s/%s:%s: %w", registry, repository, reference, err)
+	}
+	digest := response.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("error resolving %s/%s:%s: no digest returned", registry, repository, reference)
+	}
+	return digest, nil
+}
+
+// Normalize returns the registry and repository to query for an image reference
+// resolving images without a registry to Docker Hub e.g. 'nginx' is 'library/nginx'
+func Normalize(registry, repository string) (string, string) {
+	if registry == "" || registry == "docker.io" || registry == "index.docker.io" {
+		if !strings.Contains(repository, "/") {
+			repository = "library/" + repository
+		}
+		return "registry-1.docker.io", repository
+	}
+	return registry, repository
+}
+
+// manifest is the subset of an image manifest or index used
+type manifest struct {
+	MediaType string `json:"mediaType"`
+	Config    struct {
+		Digest string `json:"digest"`
+	} `json:"config"`
+	Annotations map[string]string `json:"annotations"`
+	// Manifests are set for an index of multi-platform images
+	Manifests []struct {
+		Digest   string `json:"digest"`
+		Platform struct {
+			OS           string `json:"os"`
+			Architecture string `json:"architecture"`
+		} `json:"platform"`
+	} `json:"manifests"`
+}
+
+// Labels returns the labels of the image config of reference, a tag or digest, merged with
+// the annotations of its manifest. The linux/amd64 image of multi-platform images is used,
+// falling back to the first image.
+func (c *Client) Labels(ctx context.Context, registry, repository, reference string) (map[string]string, error) {
+
+	var m manifest
+	if _, err := c.get(ctx, registry, fmt.Sprintf("/v2/%s/manifests/%s", repository, reference), manifestMediaTypes, &m); err != nil {
+		return nil, fmt.Errorf("error getting manifest of %s/%s:%s: %w", registry, repository, reference, err)
+	}
+	if len(m.Manifests) > 0 {
+		digest := m.Manifests[0].Digest
+		for _, p := range m.Manifests {
+			if p.Platform.OS == "linux" && p.Platform.Architecture == "amd64" {
+				digest = p.Digest
+				break
+			}
+		}
+		m = manifest{}
+		if _, err := c.get(ctx, registry, fmt.Sprintf("/v2/%s/manifests/%s", repository, digest), manifestMediaTypes, &m); err != nil {
+			return nil, fmt.Errorf("error getting manifest of %s/%s@%s: %w", registry, repository, digest, err)
+		}
+	}
+
+	labels := map[string]string{}
+	for k, v := range m.Annotations {
+		labels[k] = v
+	}
+	if m.Config.Digest == "" {
+		return labels, nil
+	}
+
+	var config struct {
+		Config struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"config"`
+	}
+	if _, err := c.get(ctx, registry, fmt.Sprintf("/v2/%s/blobs/%s", repository, m.Config.Digest), nil, &config); err != nil {
+		return nil, fmt.Errorf("error getting config of %s/%s:%s: %w", registry, repository, reference, err)
+	}
+	for k, v := range config.Config.Labels {
+		labels[k] = v
+	}
+	return labels, nil
+}
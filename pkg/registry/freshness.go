@@ -0,0 +1,90 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/mdevilliers/org-scrounger/pkg/mapping"
+	"github.com/mdevilliers/org-scrounger/pkg/util"
+)
+
+// Freshness compares the tags of images with the newest semver tag in their repositories
+type Freshness struct {
+	client *Client
+
+	mu sync.Mutex
+	// tags are the tags of each repository queried
+	tags map[string][]string
+}
+
+// NewFreshness returns a Freshness querying registries with the client
+func NewFreshness(client *Client) *Freshness {
+	return &Freshness{
+		client: client,
+		tags:   map[string][]string{},
+	}
+}
+
+// Decorate sets the latest version of the image, how many released versions its tag is behind
+// it and whether the image is the latest version. Tags that aren't semver e.g. 'latest' are
+// compared by digest. The digest of the image is set if the reference didn't include one.
+// Images that weren't parsed from a reference are ignored.
+func (f *Freshness) Decorate(ctx context.Context, image *mapping.Image) error {
+
+	if image.Repository == "" {
+		return nil
+	}
+	registry, repository := Normalize(image.Registry, image.Repository)
+
+	tags, err := f.repositoryTags(ctx, registry, repository)
+	if err != nil {
+		return err
+	}
+	latest, found := util.LatestSemverTag(tags)
+	if !found {
+		return nil
+	}
+	image.LatestVersion = latest
+
+	if image.Digest == "" && image.Tag != "" {
+		digest, err := f.client.Digest(ctx, registry, repository, image.Tag)
+		if err != nil {
+			return err
+		}
+		image.Digest = digest
+	}
+
+	isLatest := image.Tag == latest
+	if !isLatest && image.Digest != "" {
+		latestDigest, err := f.client.Digest(ctx, registry, repository, latest)
+		if err != nil {
+			return err
+		}
+		isLatest = image.Digest == latestDigest
+	}
+	image.IsLatest = &isLatest
+
+	if behind, ok := util.VersionsBehind(tags, latest, image.Tag); ok {
+		image.VersionsBehind = &behind
+	} else if isLatest {
+		image.VersionsBehind = new(int)
+	}
+	return nil
+}
+
+func (f *Freshness) repositoryTags(ctx context.Context, registry, repository string) ([]string, error) {
+	key := fmt.Sprintf("%s/%s", registry, repository)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if tags, found := f.tags[key]; found {
+		return tags, nil
+	}
+	tags, err := f.client.Tags(ctx, registry, repository)
+	if err != nil {
+		return nil, err
+	}
+	f.tags[key] = tags
+	return tags, nil
+}
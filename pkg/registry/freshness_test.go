@@ -0,0 +1,81 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mdevilliers/org-scrounger/pkg/mapping"
+	"github.com/mdevilliers/org-scrounger/pkg/registry/registrytest"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Freshness(t *testing.T) {
+
+	r := registrytest.New(t)
+	r.Push("team/web", "1.0.0", nil)
+	r.Push("team/web", "v1.1.0", nil)
+	r.Push("team/web", "1.1.0", nil)
+	r.Push("team/web", "1.2.0-rc.1", nil)
+	latest := r.Push("team/web", "1.2.0", nil)
+	r.Tag("team/web", "latest", latest)
+	r.Push("team/web", "nightly", nil)
+	r.Push("team/unversioned", "main", nil)
+	r.Push("team/api", "1.9.0", nil)
+	r.Push("team/api", "1.10.0", nil)
+	r.Push("team/api", "sha-0a1b2c3", nil)
+	r.Push("team/api", "20240101", nil)
+
+	testCases := []struct {
+		desc           string
+		reference      string
+		latestVersion  string
+		versionsBehind *int
+		isLatest       *bool
+	}{
+		{desc: "behind", reference: "team/web:1.0.0", latestVersion: "1.2.0", versionsBehind: ptr(2), isLatest: ptr(false)},
+		{desc: "latest", reference: "team/web:1.2.0", latestVersion: "1.2.0", versionsBehind: ptr(0), isLatest: ptr(true)},
+		{desc: "latest by digest", reference: "team/web:latest", latestVersion: "1.2.0", versionsBehind: ptr(0), isLatest: ptr(true)},
+		{desc: "not semver", reference: "team/web:nightly", latestVersion: "1.2.0", isLatest: ptr(false)},
+		{desc: "pinned digest", reference: "team/web@" + latest, latestVersion: "1.2.0", versionsBehind: ptr(0), isLatest: ptr(true)},
+		{desc: "no semver tags", reference: "team/unversioned:main"},
+		{desc: "compared as versions", reference: "team/api:1.9.0", latestVersion: "1.10.0", versionsBehind: ptr(1), isLatest: ptr(false)},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			image := mapping.NewImage(r.Host() + "/" + tC.reference)
+
+			require.Nil(t, NewFreshness(NewClient()).Decorate(context.Background(), &image))
+			require.Equal(t, tC.latestVersion, image.LatestVersion)
+			require.Equal(t, tC.versionsBehind, image.VersionsBehind)
+			require.Equal(t, tC.isLatest, image.IsLatest)
+		})
+	}
+}
+
+func Test_FreshnessIgnoresImagesWithoutAReference(t *testing.T) {
+	image := mapping.Image{Name: "static"}
+	require.Nil(t, NewFreshness(NewClient()).Decorate(context.Background(), &image))
+	require.Nil(t, image.IsLatest)
+}
+
+func Test_Normalize(t *testing.T) {
+	testCases := []struct {
+		registry   string
+		repository string
+		expected   []string
+	}{
+		{registry: "", repository: "nginx", expected: []string{"registry-1.docker.io", "library/nginx"}},
+		{registry: "docker.io", repository: "bitnami/redis", expected: []string{"registry-1.docker.io", "bitnami/redis"}},
+		{registry: "ghcr.io", repository: "org/app", expected: []string{"ghcr.io", "org/app"}},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.registry+"/"+tC.repository, func(t *testing.T) {
+			registry, repository := Normalize(tC.registry, tC.repository)
+			require.Equal(t, tC.expected, []string{registry, repository})
+		})
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
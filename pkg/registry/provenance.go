@@ -0,0 +1,52 @@
+package registry
+
+import (
+	"context"
+
+	"github.com/mdevilliers/org-scrounger/pkg/mapping"
+)
+
+// OCI annotations describing where an image was built from
+const (
+	LabelRevision = "org.opencontainers.image.revision"
+	LabelSource   = "org.opencontainers.image.source"
+)
+
+// Provenance reads the commit and repository images were built from
+type Provenance struct {
+	client *Client
+}
+
+// NewProvenance returns a Provenance querying registries with the client
+func NewProvenance(client *Client) *Provenance {
+	return &Provenance{
+		client: client,
+	}
+}
+
+// Decorate sets the revision and source of the image from the labels of its config
+// or the annotations of its manifest. Images that weren't parsed from a reference are ignored.
+func (p *Provenance) Decorate(ctx context.Context, image *mapping.Image) error {
+
+	if image.Repository == "" {
+		return nil
+	}
+	registry, repository := Normalize(image.Registry, image.Repository)
+
+	// prefer the digest as it is what is deployed
+	reference := image.Digest
+	if reference == "" {
+		reference = image.Tag
+	}
+	if reference == "" {
+		reference = "latest"
+	}
+
+	labels, err := p.client.Labels(ctx, registry, repository, reference)
+	if err != nil {
+		return err
+	}
+	image.Revision = labels[LabelRevision]
+	image.Source = labels[LabelSource]
+	return nil
+}
@@ -0,0 +1,37 @@
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/mdevilliers/org-scrounger/pkg/mapping"
+	"github.com/mdevilliers/org-scrounger/pkg/registry/registrytest"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Provenance(t *testing.T) {
+
+	r := registrytest.New(t)
+	digest := r.Push("team/web", "1.0.0", map[string]string{
+		LabelRevision: "0123456789abcdef0123456789abcdef01234567",
+		LabelSource:   "https://github.com/org/web",
+	})
+	r.Push("team/api", "1.0.0", nil)
+
+	provenance := NewProvenance(NewClient())
+	ctx := context.Background()
+
+	for _, reference := range []string{"team/web:1.0.0", "team/web@" + digest} {
+		image := mapping.NewImage(r.Host() + "/" + reference)
+		require.Nil(t, provenance.Decorate(ctx, &image))
+		require.Equal(t, "0123456789abcdef0123456789abcdef01234567", image.Revision)
+		require.Equal(t, "https://github.com/org/web", image.Source)
+	}
+
+	image := mapping.NewImage(r.Host() + "/team/api:1.0.0")
+	require.Nil(t, provenance.Decorate(ctx, &image))
+	require.Empty(t, image.Revision)
+
+	image = mapping.NewImage(r.Host() + "/team/missing:1.0.0")
+	require.NotNil(t, provenance.Decorate(ctx, &image))
+}
@@ -0,0 +1,224 @@
+// Package registrytest provides an in-memory stand-in for an OCI Distribution API registry
+package registrytest
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	token             = "test-token"
+	manifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+)
+
+// Registry serves the catalog, tags, manifests and configs of the images pushed to it
+type Registry struct {
+	*httptest.Server
+
+	// Username and Password require clients to authenticate with a bearer token if set
+	Username string
+	Password string
+	// Realm is the token server named in challenges, defaults to the registry
+	Realm string
+	// ExpiresIn is the lifetime in seconds of the tokens issued, if set
+	ExpiresIn int
+	// AbsoluteLinks links to the next page with an absolute URL
+	AbsoluteLinks bool
+
+	mu sync.Mutex
+	// tokenRequests are the number of tokens issued
+	tokenRequests int
+	// repos are the digests of the tags of each repository
+	repos map[string]map[string]string
+	// blobs are the manifests and configs by digest
+	blobs map[string][]byte
+}
+
+// New returns a running registry that is closed when the test finishes
+func New(t *testing.T) *Registry {
+	r := &Registry{
+		repos: map[string]map[string]string{},
+		blobs: map[string][]byte{},
+	}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.Close)
+	return r
+}
+
+// Host returns the host and port of the registry e.g. '127.0.0.1:5000'
+func (r *Registry) Host() string {
+	u, _ := url.Parse(r.URL)
+	return u.Host
+}
+
+// TokenRequests returns the number of tokens issued
+func (r *Registry) TokenRequests() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.tokenRequests
+}
+
+// Push adds an image with the labels to the repository returning the digest of its manifest
+func (r *Registry) Push(repository, tag string, labels map[string]string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	config := r.blob(map[string]interface{}{
+		"config":  map[string]interface{}{"Labels": labels},
+		"history": []map[string]string{{"comment": repository + ":" + tag}},
+	})
+	digest := r.blob(map[string]interface{}{
+		"schemaVersion": 2, //nolint: gomnd
+		"mediaType":     manifestMediaType,
+		"config": map[string]interface{}{
+			"mediaType": "application/vnd.oci.image.config.v1+json",
+			"digest":    config,
+			"size":      len(r.blobs[config]),
+		},
+		"layers": []interface{}{},
+	})
+	r.tag(repository, tag, digest)
+	return digest
+}
+
+// Tag points the tag at the manifest with the digest e.g. to tag an image 'latest'
+func (r *Registry) Tag(repository, tag, digest string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tag(repository, tag, digest)
+}
+
+func (r *Registry) tag(repository, tag, digest string) {
+	if r.repos[repository] == nil {
+		r.repos[repository] = map[string]string{}
+	}
+	r.repos[repository][tag] = digest
+}
+
+// blob stores v as JSON returning its digest
+func (r *Registry) blob(v interface{}) string {
+	b, _ := json.Marshal(v)
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(b))
+	r.blobs[digest] = b
+	return digest
+}
+
+func (r *Registry) serve(w http.ResponseWriter, req *http.Request) {
+
+	if req.URL.Path == "/token" {
+		user, pass, ok := req.BasicAuth()
+		if !ok || user != r.Username || pass != r.Password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.mu.Lock()
+		r.tokenRequests++
+		r.mu.Unlock()
+		writeJSON(w, map[string]interface{}{"token": token, "expires_in": r.ExpiresIn})
+		return
+	}
+
+	if r.Username != "" && req.Header.Get("Authorization") != "Bearer "+token {
+		realm := r.Realm
+		if realm == "" {
+			realm = r.URL + "/token"
+		}
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s",service="registrytest",scope="registry:catalog:*"`, realm))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	switch {
+	case path == "_catalog":
+		repos := []string{}
+		for name := range r.repos {
+			repos = append(repos, name)
+		}
+		r.page(w, req, "repositories", repos)
+	case strings.HasSuffix(path, "/tags/list"):
+		name := strings.TrimSuffix(path, "/tags/list")
+		tags, found := r.repos[name]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		all := []string{}
+		for t := range tags {
+			all = append(all, t)
+		}
+		r.page(w, req, "tags", all)
+	case strings.Contains(path, "/manifests/"):
+		name, reference, _ := strings.Cut(path, "/manifests/")
+		digest := reference
+		if !strings.HasPrefix(reference, "sha256:") {
+			digest = r.repos[name][reference]
+		}
+		b, found := r.blobs[digest]
+		if !found || r.repos[name] == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", manifestMediaType)
+		w.Header().Set("Docker-Content-Digest", digest)
+		if req.Method != http.MethodHead {
+			_, _ = w.Write(b)
+		}
+	case strings.Contains(path, "/blobs/"):
+		_, digest, _ := strings.Cut(path, "/blobs/")
+		b, found := r.blobs[digest]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(b)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// page writes the sorted items after 'last' limited to 'n' linking to the next page
+func (r *Registry) page(w http.ResponseWriter, req *http.Request, field string, items []string) {
+	sort.Strings(items)
+
+	last := req.URL.Query().Get("last")
+	n, err := strconv.Atoi(req.URL.Query().Get("n"))
+	if err != nil || n <= 0 {
+		n = len(items)
+	}
+
+	page := []string{}
+	for _, i := range items {
+		if last != "" && i <= last {
+			continue
+		}
+		page = append(page, i)
+	}
+	if len(page) > n {
+		page = page[:n]
+		next := url.URL{Path: req.URL.Path, RawQuery: url.Values{"last": {page[n-1]}, "n": {strconv.Itoa(n)}}.Encode()}
+		if r.AbsoluteLinks {
+			base, _ := url.Parse(r.URL)
+			next.Scheme, next.Host = base.Scheme, base.Host
+		}
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+	}
+	writeJSON(w, map[string][]string{field: page})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,67 @@
+package util
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+)
+
+// semverTag matches a complete semver version with an optional 'v' prefix. The semver
+// package is lenient so without it tags such as '20240101' or '1234567' would be versions.
+var semverTag = regexp.MustCompile(`^v?\d+\.\d+\.\d+(-[0-9A-Za-z.-]+)?(\+[0-9A-Za-z.-]+)?$`)
+
+// parseSemverTag returns the version of a tag that is a complete semver version
+func parseSemverTag(tag string) (*semver.Version, error) {
+	if !semverTag.MatchString(tag) {
+		return nil, errors.New("not a semver tag")
+	}
+	return semver.NewVersion(tag)
+}
+
+// LatestSemverTag returns the highest semver tag ignoring pre-releases and tags
+// that aren't versions e.g. 'latest' or 'sha-0a1b2c3'
+func LatestSemverTag(tags []string) (string, bool) {
+	var (
+		latest    *semver.Version
+		latestTag string
+	)
+	for _, t := range tags {
+		v, err := parseSemverTag(t)
+		if err != nil || v.Prerelease() != "" {
+			continue
+		}
+		if latest == nil || v.GreaterThan(latest) {
+			latest = v
+			latestTag = t
+		}
+	}
+	return latestTag, latest != nil
+}
+
+// VersionsBehind returns the number of released versions in tags that are newer than version
+// and not newer than latest. Pre-releases are ignored as are tags that only differ by a 'v' prefix.
+// False is returned if version or latest aren't semver.
+func VersionsBehind(tags []string, latest, version string) (int, bool) {
+
+	l, err := parseSemverTag(latest)
+	if err != nil {
+		return 0, false
+	}
+	// ignore any digest
+	deployed, err := parseSemverTag(strings.SplitN(version, "@", 2)[0]) //nolint: gomnd
+	if err != nil {
+		return 0, false
+	}
+
+	released := NewSet[string]()
+	for _, t := range tags {
+		v, err := parseSemverTag(t)
+		if err != nil || v.Prerelease() != "" || v.GreaterThan(l) || !v.GreaterThan(deployed) {
+			continue
+		}
+		released.Add(v.String())
+	}
+	return len(released), true
+}
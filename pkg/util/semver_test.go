@@ -0,0 +1,47 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LatestSemverTag(t *testing.T) {
+	testCases := []struct {
+		desc     string
+		tags     []string
+		expected string
+		found    bool
+	}{
+		{desc: "compared as versions", tags: []string{"1.9.0", "1.10.0", "1.2.0"}, expected: "1.10.0", found: true},
+		{desc: "prefixed", tags: []string{"v1.0.0", "v0.9.0"}, expected: "v1.0.0", found: true},
+		{desc: "pre-releases are ignored", tags: []string{"1.0.0", "2.0.0-rc.1"}, expected: "1.0.0", found: true},
+		{desc: "tags that aren't versions are ignored", tags: []string{"latest", "sha-0a1b2c3", "20240101", "1234567", "1.0", "main", "1.0.0"}, expected: "1.0.0", found: true},
+		{desc: "no versions", tags: []string{"latest", "sha-0a1b2c3"}, found: false},
+	}
+	for _, tC := range testCases {
+		t.Run(tC.desc, func(t *testing.T) {
+			latest, found := LatestSemverTag(tC.tags)
+			require.Equal(t, tC.found, found)
+			require.Equal(t, tC.expected, latest)
+		})
+	}
+}
+
+func Test_VersionsBehind(t *testing.T) {
+	tags := []string{"1.0.0", "v1.0.0", "1.1.0", "1.2.0-rc.1", "1.2.0", "latest", "20240101"}
+
+	behind, ok := VersionsBehind(tags, "1.2.0", "1.0.0")
+	require.True(t, ok)
+	require.Equal(t, 2, behind)
+
+	behind, ok = VersionsBehind(tags, "1.2.0", "1.2.0@sha256:abc")
+	require.True(t, ok)
+	require.Equal(t, 0, behind)
+
+	_, ok = VersionsBehind(tags, "1.2.0", "latest")
+	require.False(t, ok)
+
+	_, ok = VersionsBehind(tags, "1.2.0", "20240101")
+	require.False(t, ok)
+}
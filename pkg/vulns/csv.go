@@ -0,0 +1,38 @@
+package vulns
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteCSV writes a row for each repository in each group
+func WriteCSV(wr io.Writer, groups []Group) error {
+	w := csv.NewWriter(wr)
+
+	header := []string{
+		"ecosystem", "package", "advisory_id", "identifiers", "severity", "first_patched_version",
+		"repo", "manifest_path", "vulnerable_requirements", "alert_url",
+	}
+	if err := w.Write(header); err != nil {
+		return fmt.Errorf("error writing csv: %w", err)
+	}
+
+	for _, g := range groups {
+		for _, r := range g.Repos {
+			row := []string{
+				g.Ecosystem, g.Package, g.AdvisoryID, strings.Join(g.Identifiers, " "), g.Severity, g.FirstPatchedVersion,
+				r.Name, r.ManifestPath, r.VulnerableRequirements, r.AlertURL,
+			}
+			if err := w.Write(row); err != nil {
+				return fmt.Errorf("error writing csv: %w", err)
+			}
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("error writing csv: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package vulns
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type (
+	sarifLog struct {
+		Version string     `json:"version"`
+		Schema  string     `json:"$schema"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool struct {
+			Driver struct {
+				Name           string      `json:"name"`
+				InformationURI string      `json:"informationUri"`
+				Rules          []sarifRule `json:"rules"`
+			} `json:"driver"`
+		} `json:"tool"`
+		// OriginalURIBaseIDs resolves each repository's base to its URL
+		OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds"`
+		Results            []sarifResult                    `json:"results"`
+	}
+	sarifRule struct {
+		ID               string            `json:"id"`
+		ShortDescription sarifMessage      `json:"shortDescription"`
+		HelpURI          string            `json:"helpUri,omitempty"`
+		Properties       map[string]string `json:"properties,omitempty"`
+	}
+	sarifResult struct {
+		RuleID    string          `json:"ruleId"`
+		Level     string          `json:"level"`
+		Message   sarifMessage    `json:"message"`
+		Locations []sarifLocation `json:"locations"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifLocation struct {
+		PhysicalLocation struct {
+			ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+		} `json:"physicalLocation"`
+	}
+	sarifArtifactLocation struct {
+		URI       string `json:"uri"`
+		URIBaseID string `json:"uriBaseId,omitempty"`
+	}
+)
+
+// WriteSARIF writes the groups as a SARIF log with a rule per advisory
+// and a result for each repository affected. Locations are relative to the
+// root of the repository which is named by the uriBaseId.
+func WriteSARIF(wr io.Writer, groups []Group) error {
+	run := sarifRun{Results: []sarifResult{}, OriginalURIBaseIDs: map[string]sarifArtifactLocation{}}
+	run.Tool.Driver.Name = "scrng"
+	run.Tool.Driver.InformationURI = "https://github.com/mdevilliers/org-scrounger"
+	run.Tool.Driver.Rules = []sarifRule{}
+
+	rules := map[string]bool{}
+
+	for _, g := range groups {
+		ruleID := g.AdvisoryID
+		if !rules[ruleID] {
+			rules[ruleID] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{
+				ID:               ruleID,
+				ShortDescription: sarifMessage{Text: g.Summary},
+				HelpURI:          g.Permalink,
+				Properties: map[string]string{
+					"severity":  g.Severity,
+					"ecosystem": g.Ecosystem,
+				},
+			})
+		}
+		for _, r := range g.Repos {
+			msg := fmt.Sprintf("%s %s (%s) in %s", g.Package, r.VulnerableRequirements, g.Severity, r.Name)
+			if g.FirstPatchedVersion != "" {
+				msg = fmt.Sprintf("%s. Fixed in %s", msg, g.FirstPatchedVersion)
+			}
+			run.OriginalURIBaseIDs[r.Name] = sarifArtifactLocation{URI: fmt.Sprintf("%s/blob/HEAD/", r.URL)}
+
+			loc := sarifLocation{}
+			loc.PhysicalLocation.ArtifactLocation = sarifArtifactLocation{
+				URI:       strings.TrimPrefix(r.ManifestPath, "/"),
+				URIBaseID: r.Name,
+			}
+
+			run.Results = append(run.Results, sarifResult{
+				RuleID:    ruleID,
+				Level:     sarifLevel(g.Severity),
+				Message:   sarifMessage{Text: msg},
+				Locations: []sarifLocation{loc},
+			})
+		}
+	}
+
+	log := sarifLog{
+		Version: sarifVersion,
+		Schema:  sarifSchema,
+		Runs:    []sarifRun{run},
+	}
+	b, err := json.Marshal(log)
+	if err != nil {
+		return fmt.Errorf("error marshalling to sarif: %w", err)
+	}
+	_, err = wr.Write(b)
+	return err
+}
+
+func sarifLevel(severity string) string {
+	switch strings.ToUpper(severity) {
+	case "CRITICAL", "HIGH":
+		return "error"
+	case "MODERATE":
+		return "warning"
+	default:
+		return "note"
+	}
+}
@@ -0,0 +1,113 @@
+package vulns
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mdevilliers/org-scrounger/pkg/gh"
+)
+
+type (
+	// Group is a vulnerable package shared by one or more repositories
+	Group struct {
+		Ecosystem           string   `json:"ecosystem"`
+		Package             string   `json:"package"`
+		AdvisoryID          string   `json:"advisory_id"`
+		Identifiers         []string `json:"identifiers"`
+		Summary             string   `json:"summary"`
+		Severity            string   `json:"severity"`
+		Permalink           string   `json:"permalink"`
+		FirstPatchedVersion string   `json:"first_patched_version"`
+		Repos               []Repo   `json:"repos"`
+	}
+	// Repo is a repository with an open alert
+	Repo struct {
+		Name                   string `json:"name"`
+		URL                    string `json:"url"`
+		ManifestPath           string `json:"manifest_path"`
+		VulnerableRequirements string `json:"vulnerable_requirements"`
+		AlertURL               string `json:"alert_url"`
+	}
+	// RepoAlerts are the alerts for a repository
+	RepoAlerts struct {
+		Name   string
+		URL    string
+		Alerts gh.VulnerabilityAlerts
+	}
+)
+
+var severityOrder = map[string]int{
+	"CRITICAL": 0,
+	"HIGH":     1,
+	"MODERATE": 2,
+	"LOW":      3,
+}
+
+// Aggregate groups the alerts of all the repositories by ecosystem, package and advisory.
+// Groups are ordered by severity and then by the number of repositories affected.
+func Aggregate(all []RepoAlerts) []Group {
+	groups := map[string]*Group{}
+
+	for _, r := range all {
+		for _, e := range r.Alerts.Edges {
+			v := e.Node.SecurityVulnerability
+			key := fmt.Sprintf("%s|%s|%s", v.Package.Ecosystem, v.Package.Name, v.Advisory.GhsaID)
+
+			g, found := groups[key]
+			if !found {
+				g = &Group{
+					Ecosystem:           string(v.Package.Ecosystem),
+					Package:             string(v.Package.Name),
+					AdvisoryID:          string(v.Advisory.GhsaID),
+					Identifiers:         []string{},
+					Summary:             string(v.Advisory.Summary),
+					Severity:            string(v.Severity),
+					Permalink:           string(v.Advisory.Permalink),
+					FirstPatchedVersion: string(v.FirstPatchedVersion.Identifier),
+				}
+				for _, i := range v.Advisory.Identifiers {
+					g.Identifiers = append(g.Identifiers, string(i.Value))
+				}
+				groups[key] = g
+			}
+			g.Repos = append(g.Repos, Repo{
+				Name:                   r.Name,
+				URL:                    r.URL,
+				ManifestPath:           string(e.Node.VulnerableManifestPath),
+				VulnerableRequirements: string(e.Node.VulnerableRequirements),
+				AlertURL:               fmt.Sprintf("%s/security/dependabot/%d", r.URL, e.Node.Number),
+			})
+		}
+	}
+
+	ret := []Group{}
+	for _, g := range groups {
+		sort.Slice(g.Repos, func(i, j int) bool {
+			if g.Repos[i].Name == g.Repos[j].Name {
+				return g.Repos[i].ManifestPath < g.Repos[j].ManifestPath
+			}
+			return g.Repos[i].Name < g.Repos[j].Name
+		})
+		ret = append(ret, *g)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		a, b := severityRank(ret[i].Severity), severityRank(ret[j].Severity)
+		if a != b {
+			return a < b
+		}
+		if len(ret[i].Repos) != len(ret[j].Repos) {
+			return len(ret[i].Repos) > len(ret[j].Repos)
+		}
+		return ret[i].Package < ret[j].Package
+	})
+	return ret
+}
+
+func severityRank(severity string) int {
+	rank, found := severityOrder[strings.ToUpper(severity)]
+	if !found {
+		return len(severityOrder)
+	}
+	return rank
+}
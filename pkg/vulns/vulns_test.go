@@ -0,0 +1,66 @@
+package vulns
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mdevilliers/org-scrounger/pkg/gh"
+	"github.com/shurcooL/githubv4"
+	"github.com/stretchr/testify/require"
+)
+
+func alert(ecosystem, pkg, ghsa, severity, patched string) gh.VulnerabilityAlertsEdge {
+	e := gh.VulnerabilityAlertsEdge{}
+	e.Node.VulnerableManifestPath = "go.mod"
+	v := &e.Node.SecurityVulnerability
+	v.Package.Ecosystem = githubv4.String(ecosystem)
+	v.Package.Name = githubv4.String(pkg)
+	v.Advisory.GhsaID = githubv4.String(ghsa)
+	v.Severity = githubv4.String(severity)
+	v.FirstPatchedVersion.Identifier = githubv4.String(patched)
+	return e
+}
+
+func Test_Aggregate(t *testing.T) {
+
+	all := []RepoAlerts{
+		{Name: "one", URL: "https://github.com/org/one", Alerts: gh.VulnerabilityAlerts{Edges: []gh.VulnerabilityAlertsEdge{
+			alert("GO", "golang.org/x/net", "GHSA-1", "MODERATE", "0.17.0"),
+			alert("NPM", "lodash", "GHSA-2", "CRITICAL", "4.17.21"),
+		}}},
+		{Name: "two", URL: "https://github.com/org/two", Alerts: gh.VulnerabilityAlerts{Edges: []gh.VulnerabilityAlertsEdge{
+			alert("GO", "golang.org/x/net", "GHSA-1", "MODERATE", "0.17.0"),
+		}}},
+	}
+
+	groups := Aggregate(all)
+	require.Len(t, groups, 2)
+
+	// ordered by severity
+	require.Equal(t, "lodash", groups[0].Package)
+	require.Equal(t, "golang.org/x/net", groups[1].Package)
+	require.Equal(t, "0.17.0", groups[1].FirstPatchedVersion)
+	require.Len(t, groups[1].Repos, 2)
+	require.Equal(t, "two", groups[1].Repos[1].Name)
+
+	var buf bytes.Buffer
+	require.Nil(t, WriteCSV(&buf, groups))
+	require.Len(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), 4)
+
+	buf.Reset()
+	require.Nil(t, WriteSARIF(&buf, groups))
+
+	var sarif sarifLog
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &sarif))
+	require.Equal(t, "2.1.0", sarif.Version)
+	require.Len(t, sarif.Runs[0].Tool.Driver.Rules, 2)
+	require.Len(t, sarif.Runs[0].Results, 3)
+	require.Equal(t, "error", sarif.Runs[0].Results[0].Level)
+	location := sarif.Runs[0].Results[0].Locations[0].PhysicalLocation.ArtifactLocation
+	require.Equal(t, "go.mod", location.URI)
+	require.Equal(t, "one", location.URIBaseID)
+	require.Equal(t, "https://github.com/org/one/blob/HEAD/", sarif.Runs[0].OriginalURIBaseIDs["one"].URI)
+	require.Len(t, sarif.Runs[0].OriginalURIBaseIDs, 2)
+}